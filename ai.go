@@ -0,0 +1,205 @@
+package main
+
+import "math"
+
+// AIController is the built-in computer opponent.
+// While the ball is moving away from its paddle it patrols the screen,
+// and when the ball is coming towards it, it attacks (moves to where the ball is going).
+// It plays like a human would: it sees the ball with a delay, misjudges its velocity,
+// gets tired during long volleys and sometimes misses on purpose.
+// How good it is depends on its profile (see AIProfile).
+type AIController struct {
+	// The difficulty parameters
+	profile AIProfile
+
+	// Random goto position during patrol
+	randomPosition int
+
+	// Whether the ball was seen coming towards the paddle in the previous frame
+	approaching bool
+
+	// The last states of the ball, oldest first, to see the ball with a delay
+	seen []ballState
+
+	// The error added to the prediction of the ball position during the current attack
+	predictionError float64
+
+	// The factor applied to the perceived vertical velocity of the ball during the current attack
+	velocityNoise float64
+
+	// Whether the AI decided to miss the ball during the current attack
+	missing bool
+}
+
+// ballState is what the AI sees of the ball in a frame
+type ballState struct {
+	center   Vector2D
+	velocity Vector2D
+}
+
+// newAIController creates a new computer controlled opponent
+func newAIController(profile AIProfile) *AIController {
+	return &AIController{profile: profile, velocityNoise: 1}
+}
+
+// Control moves the paddle p towards the ball if the ball is seen coming at it, otherwise it patrols
+func (ai *AIController) Control(g *Game, p *Paddle) {
+	seen := ai.see(g.ball)
+
+	approaching := isMovingTowards(seen.velocity, p)
+	if approaching && !ai.approaching {
+		// the ball was just seen turning towards the paddle
+		ai.planAttack(g)
+	}
+	ai.approaching = approaching
+
+	if approaching {
+		ai.attack(g, p, seen)
+	} else {
+		ai.patrol(g, p)
+	}
+}
+
+// see records the current state of the ball and returns the state the AI reacts to,
+// which is ReactionDelay frames old
+func (ai *AIController) see(ball *Ball) ballState {
+	ai.seen = append(ai.seen, ballState{center: ballCenter(ball), velocity: ball.velocity})
+	if extra := len(ai.seen) - (ai.profile.ReactionDelay + 1); extra > 0 {
+		ai.seen = append(ai.seen[:0], ai.seen[extra:]...)
+	}
+	return ai.seen[0]
+}
+
+// isMovingTowards returns true if a ball with this velocity is moving towards the side of the screen of the paddle p
+func isMovingTowards(velocity Vector2D, p *Paddle) bool {
+	if p.position.CenterX() < halfGameScreenWidth {
+		return velocity.X < 0
+	}
+	return velocity.X > 0
+}
+
+// fatigue returns how tired the AI is, from 0 (fresh) to 0.5 (exhausted), depending on the length of the volley
+func (ai *AIController) fatigue(volleyCount int) float64 {
+	return math.Min(0.5, ai.profile.Fatigue*float64(volleyCount))
+}
+
+// planAttack picks the errors the AI makes while attacking the ball that just turned towards it
+func (ai *AIController) planAttack(g *Game) {
+	errorScale := 1 + ai.fatigue(g.volleyCount)
+
+	ai.predictionError = 0
+	if ai.profile.PredictionError > 0 {
+		ai.predictionError = g.rng.randFloat(-ai.profile.PredictionError, ai.profile.PredictionError) * errorScale
+	}
+
+	ai.velocityNoise = 1
+	if ai.profile.VelocityNoise > 0 {
+		ai.velocityNoise = 1 + g.rng.NormFloat64()*ai.profile.VelocityNoise*errorScale
+	}
+
+	ai.missing = false
+	if ai.profile.MissChance > 0 {
+		ai.missing = g.rng.Float64() < ai.profile.MissChance
+	}
+}
+
+// attack handles the AI paddle movement while the ball is seen coming towards it.
+func (ai *AIController) attack(g *Game, p *Paddle, seen ballState) {
+	// predict the Y position of the ball when it reaches the paddle, from what the AI sees
+	velocity := seen.velocity
+	velocity.Y *= ai.velocityNoise
+	radius := float64(g.ball.position.Height) / 2
+	path := ballPath(seen.center, velocity, radius, interceptX(g.ball, p), ai.profile.WallBounces)
+	if len(path) == 0 {
+		return
+	}
+	predictedY := path[len(path)-1].Y + ai.predictionError
+
+	// to miss the ball, aim far enough from it (towards the middle of the screen)
+	if ai.missing {
+		missBy := float64(p.position.Height)/2 + 2*radius
+		if predictedY < float64(halfGameScreenHeight) {
+			predictedY += missBy
+		} else {
+			predictedY -= missBy
+		}
+	}
+
+	speed := ai.profile.Speed * (1 - ai.fatigue(g.volleyCount))
+	minSpeed := speed * (1 - ai.profile.Jitter)
+
+	// a paddle with momentum must stop pushing before it gets there, to not go past it
+	stopDistance := int(math.Max(speed, g.brakingDistance(p)))
+
+	// Check if the paddle is already at the predicted Y position
+	// taking into account the paddle's speed (to avoid jittering)
+	offset := ai.profile.DeadZone
+	if p.position.CenterY() >= int(predictedY)-offset && p.position.CenterY() <= int(predictedY)+offset {
+		// stop moving
+		p.target = 0
+		return
+	} else {
+		// If the paddle is not at the predicted Y position, move it towards the predicted Y position
+		// If the paddle is lower than the predicted Y position, move it up
+		if p.position.CenterY() > int(predictedY) {
+			// if the distance is less than the paddle's speed, stop
+			if p.position.CenterY()-int(predictedY) < stopDistance {
+				p.target = 0
+				return
+			}
+			// move it up
+			p.target = g.rng.randFloat(-minSpeed, -speed)
+		}
+
+		// If the paddle is higher than the predicted Y position, move it down
+		if p.position.CenterY() < int(predictedY) {
+			// if the distance is less than the paddle's speed, stop
+			if int(predictedY)-p.position.CenterY() < stopDistance {
+				p.target = 0
+				return
+			}
+			// move it down
+			p.target = g.rng.randFloat(minSpeed, speed)
+		}
+	}
+}
+
+// patrol is making the AI paddle go randomly up and down
+// taking into account the paddle's speed (to avoid jittering)
+func (ai *AIController) patrol(g *Game, p *Paddle) {
+	speed := ai.profile.Speed
+	if ai.randomPosition == 0 {
+		halfPaddle := p.position.Height / 2
+		if screenHeight-halfPaddle <= halfPaddle {
+			// the paddle is as tall as the screen, it has nowhere to go
+			p.target = 0
+			return
+		}
+		ai.randomPosition = g.rng.randInt(0+halfPaddle, screenHeight-halfPaddle)
+	}
+
+	offset := ai.profile.DeadZone
+	if p.position.CenterY() >= ai.randomPosition-offset && p.position.CenterY() <= ai.randomPosition+offset {
+		p.target = 0
+		ai.randomPosition = 0
+	} else {
+		// if the distance is less than the speed, move the paddle to the random position
+		if math.Abs(float64(ai.randomPosition)-float64(p.position.CenterY())) < math.Max(speed, g.brakingDistance(p)) {
+			if g.rules.Momentum {
+				// a paddle with momentum cannot jump there: it stops pushing and slides towards it
+				p.target = 0
+			} else {
+				p.moveTo(p.pos.X, float64(ai.randomPosition-p.position.Height/2))
+			}
+			ai.randomPosition = 0
+			return
+		}
+		if p.position.CenterY() < ai.randomPosition {
+			p.target = speed
+		} else {
+			// if the distance is less than the speed, move the paddle to the random position
+			p.target = -speed
+		}
+	}
+
+}
@@ -3,6 +3,10 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"encoding/binary"
+	"math"
+	"time"
+
 	"github.com/hajimehoshi/ebiten/v2/audio"
 	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
 )
@@ -40,12 +44,15 @@ func LoadSounds() (map[string]*Sound, error) {
 			return nil, err
 		}
 		// player, err := audio.NewPlayer(audioContext, decoded)
-		player, err := audioContext.NewPlayer(decoded)
+		player, err := audioContext().NewPlayer(decoded)
 		if err != nil {
 			return nil, err
 		}
 		sounds[name] = &Sound{player: player}
 	}
+	for name, tone := range soundTones {
+		sounds[name] = &Sound{player: audioContext().NewPlayerFromBytes(tone.pcm(audioContext().SampleRate()))}
+	}
 	return sounds, nil
 }
 
@@ -55,5 +62,44 @@ var soundFiles = map[string][]byte{
 	"score":  scoreOgg,
 }
 
-// Initialize audio context
-var audioContext = audio.NewContext(44100)
+// Tone is a sound effect generated instead of read from a file:
+// a square wave gliding from a frequency to another (in Hz), fading out
+type Tone struct {
+	From, To float64
+	Duration time.Duration
+}
+
+// soundTones are the sound cues of the power-ups: one appears, is collected, or wears off
+var soundTones = map[string]Tone{
+	"spawn":   {From: 1200, To: 1200, Duration: 50 * time.Millisecond},
+	"powerup": {From: 400, To: 1200, Duration: 180 * time.Millisecond},
+	"expire":  {From: 800, To: 250, Duration: 180 * time.Millisecond},
+}
+
+// pcm returns the samples of the tone, in the format of the audio players: 16-bit signed little-endian stereo
+func (t Tone) pcm(sampleRate int) []byte {
+	n := int(t.Duration.Seconds() * float64(sampleRate))
+	b := make([]byte, 4*n)
+	phase := 0.0
+	for i := 0; i < n; i++ {
+		progress := float64(i) / float64(n)
+		phase += (t.From + (t.To-t.From)*progress) / float64(sampleRate)
+		v := 0.2 * (1 - progress)
+		if math.Mod(phase, 1) >= 0.5 {
+			v = -v
+		}
+		sample := uint16(int16(v * math.MaxInt16))
+		binary.LittleEndian.PutUint16(b[4*i:], sample)
+		binary.LittleEndian.PutUint16(b[4*i+2:], sample)
+	}
+	return b
+}
+
+// audioContext returns the audio context, initializing it on first use
+// so that games running without sound (e.g. headless) never open an audio device
+func audioContext() *audio.Context {
+	if c := audio.CurrentContext(); c != nil {
+		return c
+	}
+	return audio.NewContext(44100)
+}
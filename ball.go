@@ -10,75 +10,101 @@ import (
 	"math"
 )
 
-const maxBallSpeed = 15
+// maxBallSpeed is the speed of the ball after the first volleys, in pixels per frame
+var maxBallSpeed = cfg.Ball.MaxSpeed
 
 // Ball is a struct that holds information about the ball in the game
 type Ball struct {
-	// The position of the ball on the screen
-	position *rect.Rectangle
+	// The position of the ball on the screen, in whole pixels for drawing and collisions
+	position rect.Rectangle
+
+	// The position of the top left corner of the ball, with sub-pixel precision (see moveTo)
+	pos Vector2D
 
 	// The velocity (movement) of the ball
-	velocity *Vector2D
+	velocity Vector2D
+
+	// The spin of the ball, in degrees per frame (positive turns it clockwise on the screen, see SpinConfig)
+	spin float64
+
+	// The last positions of the ball, drawn behind it while it spins (the most recent first)
+	trail     [trailLength]Vector2D
+	trailSize int
+
+	// The side of the paddle that hit the ball last (noSide since it was served), who collects the power-ups it touches
+	hitter Side
+
+	// The frames left before a sticky paddle lets the ball go (0 when it is free),
+	// and where the ball is held, from the top of the paddle
+	held       int
+	heldOffset float64
 
-	// sounds map
+	// sounds map (nil when the game runs without audio)
 	sounds map[string]*Sound
 }
 
 // NewBall creates a new ball with the default values
-// The ball is 20x20 pixels and is placed in the middle of the screen
+// The ball is a square of the configured size (20x20 pixels by default) and is placed in the middle of the screen
 // The ball has a velocity of 0 (not moving) in both directions
+// The ball has no sounds, use loadSounds to enable them
 func newBall() *Ball {
 	b := &Ball{
-		position: rect.Rect(halfGameScreenWidth-20/2, halfGameScreenHeight-20/2, 20, 20),
-		velocity: &Vector2D{X: 0, Y: 0},
+		position: *rect.Rect(0, 0, cfg.Ball.Size, cfg.Ball.Size),
+		velocity: Vector2D{X: 0, Y: 0},
 	}
+	b.moveTo(float64(halfGameScreenWidth-cfg.Ball.Size/2), float64(halfGameScreenHeight-cfg.Ball.Size/2))
+	return b
+}
+
+// moveTo moves the top left corner of the ball to (x, y), and its rectangle to the nearest pixel
+func (b *Ball) moveTo(x, y float64) {
+	b.pos = Vector2D{X: x, Y: y}
+	b.position.X, b.position.Y = int(math.Round(x)), int(math.Round(y))
+}
 
+// loadSounds loads the sound effects of the ball
+func (b *Ball) loadSounds() {
 	var err error
 	b.sounds, err = LoadSounds()
 	if err != nil {
 		errSound := errors.New("error loading sounds")
 		log.Fatal(errors.Join(errSound, err))
 	}
-
-	return b
 }
 
 // Draw draws the ball on the screen
 func (b *Ball) Draw(screen *ebiten.Image) {
+	b.drawTrail(screen)
+
 	// draw ball
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Translate(float64(b.position.X), float64(b.position.Y))
 	vector.DrawFilledRect(screen, float32(b.position.X), float32(b.position.Y), float32(b.position.Width), float32(b.position.Height), color.White)
 }
 
-// Update updates the position of the ball based on its current velocity.
+// Update remembers where the ball is for its trail, and curves it if it spins (unless a paddle holds it).
+// The ball is moved by Game.moveBall, which needs to know where the paddles are.
 func (b *Ball) Update() {
-	b.position.X += int(math.Round(b.velocity.X))
-	b.position.Y += int(math.Round(b.velocity.Y))
+	b.updateTrail()
+	if b.held == 0 {
+		b.curve()
+	}
 }
 
-func (b *Ball) handleBallWallCollision() {
-	// Check if ball goes out of screen
-	if b.position.Top() < 0 || b.position.Bottom() > screenHeight {
-		if err := b.playSound("wall"); err != nil {
-			return
-		}
-		// if ball is below screen, set bottom to screen height
-		if b.position.Bottom() >= screenHeight {
-			b.position.Bottom(screenHeight)
-		} else {
-			// if ball is above screen, set top to 0
-			b.position.Top(0)
-		}
-		b.velocity.Y *= -1 // reverse Y axis velocity
+// bounceOffWall makes the ball bounce off the top or the bottom wall that it touches
+func (b *Ball) bounceOffWall() {
+	if err := b.playSound("wall"); err != nil {
+		return
 	}
+	b.velocity.Y *= -1 // reverse Y axis velocity
+	b.spinOffWall()
 }
 
 // setInitialVelocity reduces the ball speed
 // This is used when the ball is served to a player for the first time.
-func (b *Ball) setInitialVelocity() {
-	directionX := randomChoice(randFloat(-2, -1), randFloat(1, 2))
-	directionY := randFloat(-2, 2)
+func (b *Ball) setInitialVelocity(rng *Rand) {
+	directionX := rng.randomChoice(rng.randFloat(-2, -1), rng.randFloat(1, 2))
+	directionY := rng.randFloat(-2, 2)
 
 	reducer := 0.25
 	b.velocity.X = maxBallSpeed * reducer * directionX
@@ -86,26 +112,20 @@ func (b *Ball) setInitialVelocity() {
 
 }
 
-func (b *Ball) normalizeBallSpeed() {
+// normalizeBallSpeed slows the ball down to maxSpeed, if it goes faster
+func (b *Ball) normalizeBallSpeed(maxSpeed float64) {
 	// Calculate the total speed of the ball in pixels per frame
 	speed := math.Sqrt(math.Pow(b.velocity.X, 2) + math.Pow(b.velocity.Y, 2))
 
 	// Normalize the ball speed if it's larger than desired
-	if speed > maxBallSpeed {
+	if speed > maxSpeed {
 		// Adjust the X and Y components of velocity
-		factor := maxBallSpeed / speed
+		factor := maxSpeed / speed
 		b.velocity.X = b.velocity.X * factor
 		b.velocity.Y = b.velocity.Y * factor
 	}
 }
 
-func (b *Ball) atAngle(angle float64) float64 {
-	// Convert the angle to radians
-	radians := angle * math.Pi / 180
-	// Calculate the new speed for Y axis
-	return math.Round(math.Tan(radians) * b.velocity.X)
-}
-
 // accelerate increases the ball speed to its maximum value
 func (b *Ball) accelerate(amount float64) {
 	signX := 1.0
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// ControlsConfig binds the actions of the players to keys and gamepad buttons.
+// The up and down actions of a player read their gamepad only (see GamepadSlots), pause and serve read all the gamepads.
+// The menus are navigated with the up and down actions of both players, and serve chooses an item.
+type ControlsConfig struct {
+	Player1 PlayerBindings `json:"player1"`
+	Player2 PlayerBindings `json:"player2"`
+	Pause   Binding        `json:"pause"`
+	Serve   Binding        `json:"serve"`
+}
+
+// PlayerBindings are the controls moving the paddle of a player
+type PlayerBindings struct {
+	Up   Binding `json:"up"`
+	Down Binding `json:"down"`
+}
+
+// Binding is the keys and the gamepad buttons that do an action, any of them does it
+type Binding struct {
+	// The keys, by name (e.g. "ArrowUp", "W", "Space")
+	Keys []ebiten.Key `json:"keys"`
+
+	// The buttons of a gamepad with a standard layout, by name (see buttonNames)
+	Buttons []Button `json:"buttons"`
+}
+
+// Button is a button of a gamepad with a standard layout
+type Button ebiten.StandardGamepadButton
+
+// buttonNames are the names of the buttons in the configuration, with the face buttons named like on an Xbox gamepad
+var buttonNames = map[Button]string{
+	Button(ebiten.StandardGamepadButtonRightBottom):      "A",
+	Button(ebiten.StandardGamepadButtonRightRight):       "B",
+	Button(ebiten.StandardGamepadButtonRightLeft):        "X",
+	Button(ebiten.StandardGamepadButtonRightTop):         "Y",
+	Button(ebiten.StandardGamepadButtonFrontTopLeft):     "LB",
+	Button(ebiten.StandardGamepadButtonFrontTopRight):    "RB",
+	Button(ebiten.StandardGamepadButtonFrontBottomLeft):  "LT",
+	Button(ebiten.StandardGamepadButtonFrontBottomRight): "RT",
+	Button(ebiten.StandardGamepadButtonCenterLeft):       "Back",
+	Button(ebiten.StandardGamepadButtonCenterRight):      "Start",
+	Button(ebiten.StandardGamepadButtonCenterCenter):     "Home",
+	Button(ebiten.StandardGamepadButtonLeftStick):        "LeftStick",
+	Button(ebiten.StandardGamepadButtonRightStick):       "RightStick",
+	Button(ebiten.StandardGamepadButtonLeftTop):          "DPadUp",
+	Button(ebiten.StandardGamepadButtonLeftBottom):       "DPadDown",
+	Button(ebiten.StandardGamepadButtonLeftLeft):         "DPadLeft",
+	Button(ebiten.StandardGamepadButtonLeftRight):        "DPadRight",
+}
+
+// String returns the name of the button
+func (b Button) String() string {
+	if name, ok := buttonNames[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("Button%d", int(b))
+}
+
+// MarshalText writes the button as its name
+func (b Button) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText reads the button from its name
+func (b *Button) UnmarshalText(text []byte) error {
+	for button, name := range buttonNames {
+		if strings.EqualFold(name, string(text)) {
+			*b = button
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown gamepad button %q", text)
+}
+
+// defaultControls returns the controls the game was designed with
+func defaultControls() ControlsConfig {
+	buttons := func(button ebiten.StandardGamepadButton) []Button { return []Button{Button(button)} }
+	return ControlsConfig{
+		Player1: PlayerBindings{
+			Up:   Binding{Keys: []ebiten.Key{ebiten.KeyArrowUp}, Buttons: buttons(ebiten.StandardGamepadButtonLeftTop)},
+			Down: Binding{Keys: []ebiten.Key{ebiten.KeyArrowDown}, Buttons: buttons(ebiten.StandardGamepadButtonLeftBottom)},
+		},
+		Player2: PlayerBindings{
+			Up:   Binding{Keys: []ebiten.Key{ebiten.KeyW}, Buttons: buttons(ebiten.StandardGamepadButtonLeftTop)},
+			Down: Binding{Keys: []ebiten.Key{ebiten.KeyS}, Buttons: buttons(ebiten.StandardGamepadButtonLeftBottom)},
+		},
+		Pause: Binding{Keys: []ebiten.Key{ebiten.KeyEscape, ebiten.KeyP}, Buttons: buttons(ebiten.StandardGamepadButtonCenterRight)},
+		Serve: Binding{Keys: []ebiten.Key{ebiten.KeyEnter, ebiten.KeySpace}, Buttons: buttons(ebiten.StandardGamepadButtonRightBottom)},
+	}
+}
+
+// validate returns an error if an action cannot be done, or if a key or a button does two actions
+func (c ControlsConfig) validate() error {
+	for _, b := range c.bindings() {
+		if len(b.binding.Keys) == 0 && len(b.binding.Buttons) == 0 {
+			return fmt.Errorf("controls.%s needs at least a key or a button", b.name)
+		}
+		for _, key := range b.binding.Keys {
+			if other, ok := c.conflict(b, key, 0, true); ok {
+				return fmt.Errorf("controls.%s and controls.%s both use the key %s", other.name, b.name, key)
+			}
+		}
+		for _, button := range b.binding.Buttons {
+			if other, ok := c.conflict(b, 0, button, false); ok {
+				return fmt.Errorf("controls.%s and controls.%s both use the button %s", other.name, b.name, button)
+			}
+		}
+	}
+	return nil
+}
+
+// conflict returns the binding of c, other than b, that the key (or the button when isKey is false) already does.
+// The players can use the same buttons, each one on their own gamepad.
+func (c *ControlsConfig) conflict(b NamedBinding, key ebiten.Key, button Button, isKey bool) (NamedBinding, bool) {
+	for _, other := range c.bindings() {
+		if other.binding == b.binding {
+			continue
+		}
+		if isKey {
+			for _, k := range other.binding.Keys {
+				if k == key {
+					return other, true
+				}
+			}
+			continue
+		}
+		if b.player() != "" && other.player() != "" && b.player() != other.player() {
+			continue
+		}
+		for _, bt := range other.binding.Buttons {
+			if bt == button {
+				return other, true
+			}
+		}
+	}
+	return NamedBinding{}, false
+}
+
+// NamedBinding is a binding of the controls, with its name in the configuration and its label in the menus
+type NamedBinding struct {
+	name, label string
+	binding     *Binding
+}
+
+// player returns the player of the binding in the configuration (e.g. "player1"), empty for the actions of both players
+func (b NamedBinding) player() string {
+	player, _, ok := strings.Cut(b.name, ".")
+	if !ok {
+		return ""
+	}
+	return player
+}
+
+// bindings returns all the bindings of c, in the order of the menus
+func (c *ControlsConfig) bindings() []NamedBinding {
+	return []NamedBinding{
+		{"player1.up", "P1 UP", &c.Player1.Up},
+		{"player1.down", "P1 DOWN", &c.Player1.Down},
+		{"player2.up", "P2 UP", &c.Player2.Up},
+		{"player2.down", "P2 DOWN", &c.Player2.Down},
+		{"pause", "PAUSE", &c.Pause},
+		{"serve", "SERVE", &c.Serve},
+	}
+}
+
+// player returns the bindings of the player in the slot (0 for player 1)
+func (c *ControlsConfig) player(slot int) PlayerBindings {
+	if slot == 0 {
+		return c.Player1
+	}
+	return c.Player2
+}
+
+// pressed returns true if a key or a button of the binding is held down, on the gamepad of the player in the slot
+func (b Binding) pressed(slot int) bool {
+	for _, key := range b.Keys {
+		if ebiten.IsKeyPressed(key) {
+			return true
+		}
+	}
+	if id, ok := gamepads.gamepad(slot); ok {
+		for _, button := range b.Buttons {
+			if ebiten.IsStandardGamepadButtonPressed(id, ebiten.StandardGamepadButton(button)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// justPressed returns true if a key or a button of the binding was just pressed, on any gamepad
+func (b Binding) justPressed() bool {
+	for _, key := range b.Keys {
+		if inpututil.IsKeyJustPressed(key) {
+			return true
+		}
+	}
+	for _, button := range b.Buttons {
+		if gamepadJustPressed(ebiten.StandardGamepadButton(button)) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the keys and the buttons of the binding, for the menus
+func (b Binding) String() string {
+	var names []string
+	for _, key := range b.Keys {
+		names = append(names, key.String())
+	}
+	for _, button := range b.Buttons {
+		names = append(names, button.String())
+	}
+	return strings.ToUpper(strings.Join(names, ", "))
+}
+
+// justPressedControl returns the key or the gamepad button that was just pressed, if any
+func justPressedControl() (key ebiten.Key, button Button, isKey, ok bool) {
+	if keys := inpututil.AppendJustPressedKeys(nil); len(keys) > 0 {
+		return keys[0], 0, true, true
+	}
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			continue
+		}
+		if buttons := inpututil.AppendJustPressedStandardGamepadButtons(id, nil); len(buttons) > 0 {
+			return 0, Button(buttons[0]), false, true
+		}
+	}
+	return 0, 0, false, false
+}
+
+// defaultConfigPath returns where the configuration is read from by default, in the configuration directory of the user
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pong", "config.json")
+}
+
+// configPath is the file the configuration was read from, where the controls are saved when they are changed
+// (empty to not save them)
+var configPath string
+
+// saveControls writes the controls to the configuration file at path, keeping the rest of the file.
+// The file is created if it does not exist.
+func saveControls(path string, controls ControlsConfig) error {
+	c, err := loadConfig(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	c.Controls = controls
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := c.dump(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// TestControlsConflicts checks that a key or a button cannot do two actions,
+// apart from the buttons of the players who each have their own gamepad
+func TestControlsConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *ControlsConfig)
+		valid  bool
+	}{
+		{"defaults", func(c *ControlsConfig) {}, true},
+		{"player key on pause", func(c *ControlsConfig) { c.Player1.Up.Keys = []ebiten.Key{ebiten.KeyEscape} }, false},
+		{"both players on the same key", func(c *ControlsConfig) { c.Player2.Down.Keys = []ebiten.Key{ebiten.KeyArrowDown} }, false},
+		{"up and down on the same button", func(c *ControlsConfig) { c.Player1.Down.Buttons = c.Player1.Up.Buttons }, false},
+		{"both players on the same button", func(c *ControlsConfig) { c.Player2.Down.Buttons = c.Player1.Down.Buttons }, true},
+		{"player button on serve", func(c *ControlsConfig) { c.Serve.Buttons = c.Player2.Up.Buttons }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaultControls()
+			tt.change(&c)
+			if err := c.validate(); (err == nil) != tt.valid {
+				t.Errorf("validate returned %v, expected valid %t", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// BounceConfig is the angles the ball bounces off the paddles at, depending on where it hits them.
+// Both paddles bounce the ball the same way: a paddle is cut in Zones zones from its top to its bottom,
+// and the zone hit by the top of the ball gives the angle the ball leaves at, towards the other side.
+// The angles get steeper as the volley goes on: the first table of Angles is used until VolleyThresholds[0] volleys,
+// the second one until VolleyThresholds[1] volleys, and so on.
+type BounceConfig struct {
+	// The number of zones of a paddle
+	Zones int `json:"zones"`
+
+	// The volley counts at which the next table of angles is used
+	VolleyThresholds []int `json:"volleyThresholds"`
+
+	// The tables of angles, one angle per zone in degrees from the horizontal (negative goes up, positive goes down)
+	Angles [][]float64 `json:"angles"`
+}
+
+// defaultBounceConfig returns the angles the game was designed with
+func defaultBounceConfig() BounceConfig {
+	return BounceConfig{
+		Zones:            8,
+		VolleyThresholds: []int{4, 8},
+		Angles: [][]float64{
+			{-45, -30, -15, 0, 0, 15, 30, 45},
+			{-60, -45, -30, -15, 0, 0, 15, 30},
+			{-75, -60, -45, -30, -15, 0, 15, 30},
+		},
+	}
+}
+
+// validate returns an error if the bounce tables cannot be used with paddles of the given height
+func (b BounceConfig) validate(paddleHeight int) error {
+	if b.Zones < 1 || b.Zones > paddleHeight {
+		return fmt.Errorf("bounce.zones must be between 1 and the paddle height %d, got %d", paddleHeight, b.Zones)
+	}
+	for i, threshold := range b.VolleyThresholds {
+		if threshold < 1 || (i > 0 && threshold <= b.VolleyThresholds[i-1]) {
+			return fmt.Errorf("bounce.volleyThresholds must be positive and increasing, got %v", b.VolleyThresholds)
+		}
+	}
+	if len(b.Angles) != len(b.VolleyThresholds)+1 {
+		return fmt.Errorf("bounce.angles must have %d tables (one more than the volley thresholds), got %d",
+			len(b.VolleyThresholds)+1, len(b.Angles))
+	}
+	for i, angles := range b.Angles {
+		if len(angles) != b.Zones {
+			return fmt.Errorf("bounce.angles[%d] must have %d angles (one per zone), got %d", i, b.Zones, len(angles))
+		}
+		for _, angle := range angles {
+			if angle <= -90 || angle >= 90 {
+				return fmt.Errorf("bounce.angles[%d] must be between -90 and 90 degrees (excluded), got %v", i, angle)
+			}
+		}
+	}
+	return nil
+}
+
+// angles returns the table of angles used during the volley
+func (b BounceConfig) angles(volleyCount int) []float64 {
+	for i, threshold := range b.VolleyThresholds {
+		if volleyCount < threshold {
+			return b.Angles[i]
+		}
+	}
+	return b.Angles[len(b.Angles)-1]
+}
+
+// zone returns the zone of the paddle p that the ball hits (the one of the top of the ball)
+func (b BounceConfig) zone(ball *Ball, p *Paddle) int {
+	zone := (ball.position.Top() - p.position.Top()) / (p.position.Height / b.Zones)
+	if zone < 0 {
+		return 0
+	}
+	if zone >= b.Zones {
+		return b.Zones - 1
+	}
+	return zone
+}
+
+// bounce makes the ball bounce off the paddle p, at the angle of the zone it hits
+func (b *Ball) bounce(p *Paddle, volleyCount int) {
+	b.velocity.X *= -1 // reverse the ball direction on X axis
+	b.velocity.Y = b.atAngle(cfg.Bounce.angles(volleyCount)[cfg.Bounce.zone(b, p)])
+}
+
+// atAngle returns the velocity on the Y axis that makes the ball leave at the angle (in degrees from the horizontal),
+// keeping its velocity on the X axis
+func (b *Ball) atAngle(angle float64) float64 {
+	// Convert the angle to radians
+	radians := angle * math.Pi / 180
+	// Calculate the new speed for Y axis (not rounded, the ball moves by fractions of pixels)
+	return math.Tan(radians) * math.Abs(b.velocity.X)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// newCourt returns a game being played with the ball and the paddles where the test puts them (nobody controls the paddles)
+func newCourt() *Game {
+	g := newSimulation(1)
+	g.player.controller, g.enemy.controller = nil, nil
+	g.state = playing
+	return g
+}
+
+// exhaustive makes TestNoTunnelling fire the ball from every angle, instead of every 7 degrees (about 2 million shots)
+var exhaustive = flag.Bool("exhaustive", false, "fire the ball at the paddles from every angle in TestNoTunnelling")
+
+// TestNoTunnelling fires the ball at both paddles from many angles (every angle with -exhaustive), at every speed
+// up to 4 times the maximum speed and at every height of the paddle, and checks that the ball never goes through a paddle
+func TestNoTunnelling(t *testing.T) {
+	useConfig(t, defaultConfig())
+	step := 7
+	if *exhaustive {
+		step = 1
+	}
+
+	shots := 0
+	maxSpeed := int(math.Ceil(4 * cfg.Ball.MaxSpeed))
+	for _, side := range []string{"player", "enemy"} {
+		for angle := 0; angle < 360; angle += step {
+			for speed := 1; speed <= maxSpeed; speed++ {
+				for offset := -cfg.Ball.Size; offset <= cfg.Paddle.Height; offset += 3 {
+					if err := checkShot(newCourt(), side, float64(angle), float64(speed), offset); err != nil {
+						t.Fatal(err)
+					}
+					shots++
+				}
+			}
+		}
+	}
+	t.Logf("%d shots", shots)
+}
+
+// checkShot fires the ball at the paddle of the side, at the angle (in degrees, 0 goes right) and the speed (in pixels per frame).
+// The ball starts a few frames away from the front of the paddle, aimed to cross it at the offset from its top.
+// It returns an error if the ball overlaps the paddle, or goes on as if the paddle was not there although its path crosses it.
+func checkShot(g *Game, side string, angle, speed float64, offset int) error {
+	ball := g.ball
+	ball.velocity = Vector2D{X: speed * math.Cos(angle*math.Pi/180), Y: speed * math.Sin(angle*math.Pi/180)}
+
+	paddle, front := g.player.paddle, 1.0
+	if side == "enemy" {
+		paddle, front = g.enemy.paddle, -1
+	}
+	if ball.velocity.X*front <= 0.01 {
+		// the ball does not go towards the front of the paddle
+		return nil
+	}
+
+	// go back in time from where the ball crosses the front of the paddle
+	frames := 3.5
+	crossX := float64(paddle.position.Left() - ball.position.Width)
+	if side == "enemy" {
+		crossX = float64(paddle.position.Right())
+	}
+	crossY := float64(paddle.position.Top() + offset)
+	ball.moveTo(crossX-ball.velocity.X*frames, crossY-ball.velocity.Y*frames)
+	if ball.position.Top() < 0 || ball.position.Bottom() > screenHeight {
+		// the ball would bounce off a wall on the way
+		return nil
+	}
+
+	p := boxOf(paddle.pos, paddle.position)
+	for i := 0; i < 10; i++ {
+		before, velocity := boxOf(ball.pos, ball.position), ball.velocity
+		if err := g.moveBall(ball); err != nil {
+			return err
+		}
+		switch {
+		case overlaps(boxOf(ball.pos, ball.position), p):
+			return fmt.Errorf("the ball overlaps the %s paddle (angle %v, speed %v, offset %d, frame %d)", side, angle, speed, offset, i+1)
+		case ball.velocity == velocity && straightPathHits(before, velocity, p):
+			return fmt.Errorf("the ball went through the %s paddle (angle %v, speed %v, offset %d, frame %d)", side, angle, speed, offset, i+1)
+		}
+		if ball.velocity.X*front < 0 || ball.position.Left() <= 0 || ball.position.Right() >= screenWidth {
+			// the ball bounced back, or it is out
+			return nil
+		}
+	}
+	return nil
+}
+
+// straightPathHits returns true if the ball, moving from where it was by its velocity without hitting anything,
+// would overlap the paddle at some point of the frame (the frame is cut in small steps)
+func straightPathHits(ball AABB, velocity Vector2D, paddle AABB) bool {
+	const steps = 200
+	for i := 0; i <= steps; i++ {
+		step := ball
+		step.X += velocity.X * float64(i) / steps
+		step.Y += velocity.Y * float64(i) / steps
+		if overlaps(step, paddle) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestPaddleEdges plays a few frames with the ball on the edges and the corners of the player's paddle
+// (at 1190, 300 and 20x110 pixels, the ball is 20x20 pixels)
+func TestPaddleEdges(t *testing.T) {
+	tests := []struct {
+		name     string
+		ball     Vector2D // where the ball starts
+		velocity Vector2D // of the ball
+		paddle   float64  // the vertical velocity the paddle is pushed at
+		saved    bool     // whether the ball must be sent back
+	}{
+		{"front", Vector2D{X: 1160, Y: 340}, Vector2D{X: 15}, 0, true},
+		{"top corner of the front", Vector2D{X: 1145, Y: 255}, Vector2D{X: 10, Y: 10}, 0, true},
+		{"bottom corner of the front", Vector2D{X: 1145, Y: 435}, Vector2D{X: 10, Y: -10}, 0, true},
+		{"top edge, past the front", Vector2D{X: 1195, Y: 270}, Vector2D{X: 10, Y: 12}, 0, false},
+		{"bottom edge, past the front", Vector2D{X: 1195, Y: 420}, Vector2D{X: 10, Y: -12}, 0, false},
+		{"paddle moving up into the ball past the front", Vector2D{X: 1195, Y: 285}, Vector2D{X: 10, Y: 5}, -15, false},
+		{"paddle moving down into the ball past the front", Vector2D{X: 1195, Y: 395}, Vector2D{X: 10, Y: -5}, 15, false},
+		{"paddle moving up into a ball coming up", Vector2D{X: 1195, Y: 285}, Vector2D{X: 10, Y: -5}, -15, false},
+	}
+	useConfig(t, defaultConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newCourt()
+			p := g.player.paddle
+			p.moveTo(p.pos.X, 300)
+			p.target = tt.paddle
+			g.ball.moveTo(tt.ball.X, tt.ball.Y)
+			g.ball.velocity = tt.velocity
+
+			for i := 0; i < 3; i++ {
+				if err := g.Update(); err != nil {
+					t.Fatal(err)
+				}
+				if overlaps(boxOf(g.ball.pos, g.ball.position), boxOf(p.pos, p.position)) {
+					t.Fatalf("frame %d: the ball at %v overlaps the paddle at %v", i+1, g.ball.pos, p.pos)
+				}
+			}
+			if saved := g.volleyCount > 0; saved != tt.saved {
+				t.Errorf("volleys %d, saved %t, expected %t", g.volleyCount, saved, tt.saved)
+			}
+			if saved := g.ball.velocity.X < 0; saved != tt.saved {
+				t.Errorf("the ball goes %v, sent back %t, expected %t", g.ball.velocity, saved, tt.saved)
+			}
+			if !tt.saved && tt.paddle != 0 && (g.ball.velocity.Y < 0) != (tt.paddle < 0) {
+				t.Errorf("the ball goes %v, the paddle did not push it along", g.ball.velocity)
+			}
+		})
+	}
+}
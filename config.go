@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"time"
+)
+
+// Config holds the tunables of the game. It is read from a JSON file with -config,
+// and the fields missing from the file keep their default value (see `pong -dump-config`).
+type Config struct {
+	// The size of the game screen, in pixels
+	Screen ScreenConfig `json:"screen"`
+
+	// The preset of the match rules (see matchRulesNames)
+	Rules string `json:"rules"`
+
+	// The points needed to win with the classic rules
+	PointsToWin int `json:"pointsToWin"`
+
+	// The rules of the match when Rules is "custom"
+	CustomRules CustomRulesConfig `json:"customRules"`
+
+	// The size and the speed of the ball
+	Ball BallConfig `json:"ball"`
+
+	// The size, the position and the speed of the paddles
+	Paddle PaddleConfig `json:"paddle"`
+
+	// The angles the ball bounces off the paddles at
+	Bounce BounceConfig `json:"bounce"`
+
+	// How the ball spins, when the rules allow it
+	Spin SpinConfig `json:"spin"`
+
+	// How the paddles accelerate and slow down, when the rules give them momentum (the limits of all the rules)
+	Momentum MomentumConfig `json:"momentum"`
+
+	// How the power-ups appear and how long they last, when the rules have power-ups
+	PowerUps PowerUpConfig `json:"powerUps"`
+
+	// How the gamepads control the paddles
+	Gamepad GamepadConfig `json:"gamepad"`
+
+	// The keys and the gamepad buttons of the actions of the players
+	Controls ControlsConfig `json:"controls"`
+}
+
+// ScreenConfig is the size of the game screen
+type ScreenConfig struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// BallConfig is the size and the speed of the ball
+type BallConfig struct {
+	// The width and height of the ball, in pixels
+	Size int `json:"size"`
+
+	// The speed of the ball after the first volleys, in pixels per frame
+	MaxSpeed float64 `json:"maxSpeed"`
+}
+
+// PaddleConfig is the size, the position and the speed of the paddles
+type PaddleConfig struct {
+	// The size of the paddles, in pixels
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// The distance between a paddle and its edge of the screen, in pixels
+	Margin int `json:"margin"`
+
+	// The speed of a paddle controlled by a player, in pixels per frame
+	// (without momentum, see MomentumConfig.MaxSpeed otherwise)
+	Speed float64 `json:"speed"`
+}
+
+// Duration is a duration written as text in the configuration file (e.g. "90s")
+type Duration time.Duration
+
+// MarshalText writes the duration as text (e.g. "1m30s")
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText reads the duration from text (e.g. "90s")
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// cfg is the configuration of the game, set at startup
+var cfg = defaultConfig()
+
+// defaultConfig returns the configuration the game was designed with
+func defaultConfig() Config {
+	return Config{
+		Screen:      ScreenConfig{Width: 1280, Height: 720},
+		Rules:       "classic",
+		PointsToWin: pointsToWin,
+		CustomRules: defaultCustomRules(),
+		Ball:        BallConfig{Size: 20, MaxSpeed: 15},
+		Paddle:      PaddleConfig{Width: 20, Height: 110, Margin: 70, Speed: 15},
+		Bounce:      defaultBounceConfig(),
+		Spin:        defaultSpinConfig(),
+		Momentum:    defaultMomentumConfig(),
+		PowerUps:    defaultPowerUpConfig(),
+		Gamepad:     defaultGamepadConfig(),
+		Controls:    defaultControls(),
+	}
+}
+
+// loadConfig reads a configuration file, on top of the default configuration
+func loadConfig(path string) (Config, error) {
+	c := defaultConfig()
+	f, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	d := json.NewDecoder(f)
+	d.DisallowUnknownFields() // catch the typos
+	if err := d.Decode(&c); err != nil {
+		return c, fmt.Errorf("%s is not a valid config: %w", path, err)
+	}
+	return c, nil
+}
+
+// dump writes the configuration as JSON, in the format read by loadConfig
+func (c Config) dump(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(c)
+}
+
+// hash returns a hash of the configuration the simulation depends on, to check that two games play with the same one.
+// The gamepads and the controls are left out, each player can set their own.
+func (c Config) hash() uint32 {
+	c.Gamepad, c.Controls = GamepadConfig{}, ControlsConfig{}
+	h := fnv.New32a()
+	_ = json.NewEncoder(h).Encode(c)
+	return h.Sum32()
+}
+
+// validate returns an error if the configuration makes no sense
+func (c Config) validate() error {
+	switch {
+	case c.Screen.Width < 800 || c.Screen.Height < 600: // the menus need 800x600 pixels
+		return fmt.Errorf("screen must be at least 800x600, got %dx%d", c.Screen.Width, c.Screen.Height)
+	case c.PointsToWin < 1:
+		return fmt.Errorf("pointsToWin must be at least 1, got %d", c.PointsToWin)
+	case c.Ball.Size < 1 || c.Ball.Size > c.Screen.Height/4:
+		return fmt.Errorf("ball.size must be between 1 and %d (a quarter of the screen height), got %d", c.Screen.Height/4, c.Ball.Size)
+	case c.Ball.MaxSpeed <= 0:
+		return fmt.Errorf("ball.maxSpeed must be positive, got %v", c.Ball.MaxSpeed)
+	case c.Paddle.Width < 1 || c.Paddle.Height < 1:
+		return fmt.Errorf("paddle.width and paddle.height must be at least 1, got %dx%d", c.Paddle.Width, c.Paddle.Height)
+	case c.Paddle.Height >= c.Screen.Height: // the paddles need room to move
+		return fmt.Errorf("paddle.height must be less than the screen height %d, got %d", c.Screen.Height, c.Paddle.Height)
+	case c.Paddle.Margin < 0 || c.Paddle.Margin+c.Paddle.Width >= c.Screen.Width/2:
+		return fmt.Errorf("paddle.margin must keep the paddle on its half of the screen, got %d", c.Paddle.Margin)
+	case c.Paddle.Speed <= 0:
+		return fmt.Errorf("paddle.speed must be positive, got %v", c.Paddle.Speed)
+	}
+	if c.Rules == "custom" {
+		if err := c.CustomRules.validate(); err != nil {
+			return err
+		}
+	} else if _, err := lookupMatchRules(c.Rules); err != nil {
+		return err
+	}
+	if err := c.Bounce.validate(c.Paddle.Height); err != nil {
+		return err
+	}
+	if err := c.Spin.validate(); err != nil {
+		return err
+	}
+	if err := c.Momentum.validate(); err != nil {
+		return err
+	}
+	if err := c.PowerUps.validate(); err != nil {
+		return err
+	}
+	if err := c.Gamepad.validate(); err != nil {
+		return err
+	}
+	return c.Controls.validate()
+}
+
+// apply makes the configuration the one of the game
+func (c Config) apply() {
+	cfg = c
+	screenWidth, screenHeight = c.Screen.Width, c.Screen.Height
+	halfGameScreenWidth, halfGameScreenHeight = screenWidth/2, screenHeight/2
+	maxBallSpeed = c.Ball.MaxSpeed
+
+	for name, rules := range matchRules {
+		rules.MomentumLimits = c.Momentum
+		matchRules[name] = rules
+	}
+	classic := matchRules["classic"]
+	classic.PointsToWin = c.PointsToWin
+	matchRules["classic"] = classic
+	defaultMatchRules = classic
+}
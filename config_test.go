@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCustomRulesConfig reads the custom rules from configuration files, and checks the ones that make no sense
+func TestCustomRulesConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		json  string
+		valid bool
+		rules MatchRules // of a valid config
+	}{
+		{"defaults", `{"rules": "custom"}`, true, MatchRules{Name: "custom", PointsToWin: pointsToWin, Sets: 1}},
+		{"timed", `{"rules": "custom", "customRules": {"pointsToWin": 0, "timeLimit": "90s"}}`, true, MatchRules{Name: "custom", Sets: 1, TimeLimit: 90 * time.Second}},
+		{"sets", `{"rules": "custom", "customRules": {"pointsToWin": 7, "winByTwo": true, "sets": 3}}`, true, MatchRules{Name: "custom", PointsToWin: 7, WinByTwo: true, Sets: 3}},
+		{"no end", `{"rules": "custom", "customRules": {"pointsToWin": 0}}`, false, MatchRules{}},
+		{"no sets", `{"rules": "custom", "customRules": {"sets": 0}}`, false, MatchRules{}},
+		{"other rules", `{"rules": "quick", "customRules": {"sets": 0}}`, true, MatchRules{}}, // only the custom rules are checked
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := loadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.validate()
+			if (err == nil) != tt.valid {
+				t.Fatalf("validate returned %v, expected valid %t", err, tt.valid)
+			}
+			if err == nil && c.Rules == "custom" && c.CustomRules.rules() != tt.rules {
+				t.Errorf("custom rules %+v, expected %+v", c.CustomRules.rules(), tt.rules)
+			}
+		})
+	}
+}
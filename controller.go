@@ -0,0 +1,81 @@
+package main
+
+import "fmt"
+
+// Controller moves a paddle. Every frame it is asked to set the velocity it wants for the paddle it is bound to
+// (see Paddle.target).
+// Any side of the court can be bound to any controller (keyboard, AI, scripts, replays, etc).
+type Controller interface {
+	Control(g *Game, p *Paddle)
+}
+
+// ControllerFunc is a function used as a Controller, e.g. for scripted paddles in tests and bots
+type ControllerFunc func(g *Game, p *Paddle)
+
+// Control calls f(g, p)
+func (f ControllerFunc) Control(g *Game, p *Paddle) {
+	f(g, p)
+}
+
+// InputSource returns the controls held down in the current frame for the paddle p
+type InputSource func(g *Game, p *Paddle) PaddleInput
+
+// InputController moves a paddle from up/down controls, like a human would do with the keyboard.
+// The controls can come from any source: the keyboard, a script, a replay, etc.
+type InputController struct {
+	// Where the controls come from
+	source InputSource
+}
+
+// newInputController creates a controller that moves the paddle with the controls returned by source
+func newInputController(source InputSource) *InputController {
+	return &InputController{source: source}
+}
+
+// Control reads the controls of the current frame and updates the velocity wanted for the paddle
+func (c *InputController) Control(g *Game, p *Paddle) {
+	p.input(c.source(g, p), g.paddleSpeed())
+}
+
+// followBall is a simple input source that keeps the paddle in line with the ball
+func followBall(g *Game, p *Paddle) PaddleInput {
+	paddleY := p.position.CenterY()
+	ballY := g.ball.position.CenterY()
+
+	offset := 10
+	return PaddleInput{
+		Up:   ballY < paddleY-offset,
+		Down: ballY > paddleY+offset,
+	}
+}
+
+// controllerNames lists the names accepted by newController
+var controllerNames = []string{"keyboard", "ws", "mouse", "touch", "cpu", "follow", "idle"}
+
+// newController creates a controller from its name (the AI plays with the given profile):
+//   - keyboard: the arrow keys, or the gamepad of player 1
+//   - ws: the W (up) and S (down) keys, or the gamepad of player 2
+//   - mouse: the paddle follows the mouse cursor
+//   - touch: a finger dragged on the half of the screen of the paddle
+//   - cpu: the built-in AI
+//   - follow: a bot that keeps the paddle in line with the ball
+//   - idle: the paddle never moves
+func newController(name string, profile AIProfile) (Controller, error) {
+	switch name {
+	case "keyboard":
+		return newInputController(readPlayer1), nil
+	case "ws":
+		return newInputController(readPlayer2), nil
+	case "mouse":
+		return newInputController(readMouse), nil
+	case "touch":
+		return newInputController(readTouch()), nil
+	case "cpu":
+		return newAIController(profile), nil
+	case "follow":
+		return newInputController(followBall), nil
+	case "idle":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown controller %q (expected one of %v)", name, controllerNames)
+}
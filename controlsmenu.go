@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// controlsHelp is the line shown above the actions of the controls menu
+const controlsHelp = "CHOOSE AN ACTION TO CHANGE ITS KEY OR BUTTON"
+
+// newControlsMenu creates the menu to bind the actions to other keys and gamepad buttons, and back leaves the menu.
+// Choosing an action waits for a key or a button: a key replaces the keys of the action, and a button its buttons.
+// A key or a button that already does another action is refused, and the menu waits for another one.
+// The controls are saved to the configuration file every time they change.
+func newControlsMenu(back func()) *Menu {
+	m := newMenu("CONTROLS")
+	m.info = []string{controlsHelp}
+
+	bindings := cfg.Controls.bindings()
+	for i, b := range bindings {
+		i, b := i, b
+		m.items = append(m.items, MenuItem{
+			label: bindingLabel(b),
+			action: func() {
+				m.info[0] = "PRESS A KEY OR A BUTTON FOR " + b.label
+				m.listen = func() {
+					key, button, isKey, ok := justPressedControl()
+					if !ok {
+						return
+					}
+					if other, used := cfg.Controls.conflict(b, key, button, isKey); used {
+						name := button.String()
+						if isKey {
+							name = key.String()
+						}
+						m.info[0] = strings.ToUpper(name) + " IS USED BY " + other.label + ", PRESS ANOTHER"
+						return
+					}
+					if isKey {
+						b.binding.Keys = []ebiten.Key{key}
+					} else {
+						b.binding.Buttons = []Button{button}
+					}
+					m.items[i].label = bindingLabel(b)
+					m.info[0] = controlsHelp
+					m.listen = nil
+					saveControlsConfig()
+				}
+			},
+		})
+	}
+
+	m.items = append(m.items, MenuItem{
+		label: "RESET TO DEFAULTS",
+		action: func() {
+			cfg.Controls = defaultControls()
+			for i, b := range bindings {
+				m.items[i].label = bindingLabel(b)
+			}
+			saveControlsConfig()
+		},
+	})
+
+	m.items = append(m.items, MenuItem{label: "BACK", action: back})
+	return m
+}
+
+// bindingLabel returns the label of the menu item of a binding
+func bindingLabel(b NamedBinding) string {
+	return b.label + ": " + b.binding.String()
+}
+
+// saveControlsConfig saves the controls to the configuration file, if there is one
+func saveControlsConfig() {
+	if configPath == "" {
+		return
+	}
+	if err := saveControls(configPath, cfg.Controls); err != nil {
+		log.Printf("cannot save the controls: %v", err)
+	}
+}
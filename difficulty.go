@@ -0,0 +1,131 @@
+package main
+
+import "fmt"
+
+// AIProfile holds the parameters that make the AI easier or harder to beat
+type AIProfile struct {
+	// The name of the profile (e.g. "normal")
+	Name string
+
+	// The speed of the AI paddle, in pixels per frame
+	// (when the paddles have momentum, it cannot go faster than the paddles of the players, see MomentumConfig)
+	Speed float64
+
+	// Jitter randomly slows down the paddle while attacking: its speed is picked every frame
+	// between Speed*(1-Jitter) and Speed (0 always moves at full speed)
+	Jitter float64
+
+	// The reaction time of the AI, in frames: it sees the ball where it was this many frames ago
+	ReactionDelay int
+
+	// The maximum error, in pixels, when predicting where the ball will reach the paddle.
+	// A new error is picked every time the ball comes towards the AI.
+	PredictionError float64
+
+	// The standard deviation of the error made when perceiving the vertical velocity of the ball,
+	// relative to the velocity (e.g. 0.1 is 10%). A new error is picked every time the ball comes towards the AI.
+	VelocityNoise float64
+
+	// Fatigue slows down the paddle and increases the errors as the volley gets longer:
+	// every volley costs this fraction of the speed (down to half the speed),
+	// and increases the errors by the same fraction
+	Fatigue float64
+
+	// The probability to deliberately miss the ball, every time it comes towards the AI
+	MissChance float64
+
+	// Whether the prediction accounts for the ball bouncing off the top and bottom walls
+	WallBounces bool
+
+	// The paddle stops moving when it is this many pixels away from where it wants to be
+	DeadZone int
+}
+
+// aiProfiles are the difficulty presets of the AI
+var aiProfiles = map[string]AIProfile{
+	"easy": {
+		Name:            "easy",
+		Speed:           8,
+		Jitter:          0.5,
+		ReactionDelay:   20,
+		PredictionError: 60,
+		VelocityNoise:   0.2,
+		Fatigue:         0.02,
+		MissChance:      0.15,
+		DeadZone:        20,
+	},
+	"normal": {
+		Name:          "normal",
+		Speed:         13,
+		Jitter:        0.5,
+		ReactionDelay: 6,
+		VelocityNoise: 0.05,
+		Fatigue:       0.005,
+		MissChance:    0.03,
+		DeadZone:      10,
+	},
+	"hard": {
+		Name:          "hard",
+		Speed:         15,
+		Jitter:        0.25,
+		ReactionDelay: 3,
+		VelocityNoise: 0.02,
+		MissChance:    0.01,
+		WallBounces:   true,
+		DeadZone:      10,
+	},
+	"insane": {
+		Name:        "insane",
+		Speed:       20,
+		WallBounces: true,
+		DeadZone:    5,
+	},
+}
+
+// aiProfileNames lists the names of the difficulty presets, from the easiest to the hardest
+var aiProfileNames = []string{"easy", "normal", "hard", "insane"}
+
+// defaultAIProfile is the difficulty used when none is chosen
+var defaultAIProfile = aiProfiles["normal"]
+
+// lookupAIProfile returns the difficulty preset with the given name
+func lookupAIProfile(name string) (AIProfile, error) {
+	profile, ok := aiProfiles[name]
+	if !ok {
+		return AIProfile{}, fmt.Errorf("unknown difficulty %q (expected one of %v)", name, aiProfileNames)
+	}
+	return profile, nil
+}
+
+// validate returns an error if the parameters of the profile make no sense
+func (p AIProfile) validate() error {
+	switch {
+	case p.Speed <= 0:
+		return fmt.Errorf("AI speed must be positive, got %v", p.Speed)
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("AI jitter must be between 0 and 1, got %v", p.Jitter)
+	case p.ReactionDelay < 0:
+		return fmt.Errorf("AI reaction delay cannot be negative, got %d", p.ReactionDelay)
+	case p.PredictionError < 0:
+		return fmt.Errorf("AI prediction error cannot be negative, got %v", p.PredictionError)
+	case p.VelocityNoise < 0:
+		return fmt.Errorf("AI velocity noise cannot be negative, got %v", p.VelocityNoise)
+	case p.Fatigue < 0 || p.Fatigue > 1:
+		return fmt.Errorf("AI fatigue must be between 0 and 1, got %v", p.Fatigue)
+	case p.MissChance < 0 || p.MissChance > 1:
+		return fmt.Errorf("AI miss chance must be between 0 and 1, got %v", p.MissChance)
+	case p.DeadZone < 0:
+		return fmt.Errorf("AI dead zone cannot be negative, got %d", p.DeadZone)
+	}
+	return nil
+}
+
+// setDifficulty changes the difficulty of the game, for all the paddles controlled by the AI
+func (g *Game) setDifficulty(profile AIProfile) {
+	g.difficulty = profile
+	for _, c := range []Controller{g.player.controller, g.enemy.controller} {
+		if ai, ok := c.(*AIController); ok {
+			ai.profile = profile
+		}
+	}
+}
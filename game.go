@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"log"
+	"time"
 )
 
 // GameObject is considered anything that can be updated and drawn on the screen
@@ -22,10 +23,16 @@ type Game struct {
 	// The current turn of the player (user or computer)
 	turn playerTurn
 
+	// The number of updates since the game was created (the current frame while updating)
+	frame int
+
 	// The number of times the ball has been hit back and forth
 	// the more times it is hit, the faster it goes to increase the difficulty
 	volleyCount int
 
+	// The highest volley count of the match
+	longestVolley int
+
 	// The ball in the game
 	ball *Ball
 
@@ -35,31 +42,93 @@ type Game struct {
 	// The enemy's paddle
 	enemy *Enemy
 
-	// A slice to store all the game objects (ball, player, enemy)
-	// used to update and draw them all at once
+	// A slice to store all the game objects (ball, player, enemy, and the extra balls and the power-ups)
+	// used to update and draw them all at once (see updateObjects)
 	objects []GameObject
 
+	// The power-ups on the court, the effects of the power-ups collected, the balls added by multi-ball,
+	// and the frames since the last power-up appeared (only with power-ups, see PowerUpConfig)
+	powerUps     []*PowerUp
+	effects      []Effect
+	extraBalls   []*Ball
+	powerUpClock int
+
 	// HUD for the game (used to display score and the result)
 	hud *HUD
+
+	// The seed of the random number generator, a match can be reproduced by playing it with the same seed
+	seed int64
+
+	// The random number generator used for all the gameplay randomness
+	rng *Rand
+
+	// Show debug information (e.g. the seed) on the screen
+	debug bool
+
+	// Who is playing the match (one player versus the computer or two players)
+	mode GameMode
+
+	// The difficulty of the paddles controlled by the AI
+	difficulty AIProfile
+
+	// The rules deciding who wins the match
+	rules MatchRules
+
+	// The menu shown while the match is paused
+	menu *Menu
+
+	// Whether the match is played by local players, who can pause it (not online or in a replay)
+	local bool
+
+	// The state to go back to when the match is resumed
+	resumeState GameState
+
+	// Leaves the match, when QUIT is chosen in the pause menu (nil to not offer it)
+	leave func()
 }
 
-func newGame() *Game {
+// newGame creates a game played in a window, with sounds.
+// Player 1 controls the player (arrow keys or the first gamepad), and the enemy is controlled by
+// the AI (normal difficulty) in one player mode or by player 2 (W and S or the second gamepad) in two players mode.
+func newGame(seed int64, mode GameMode) *Game {
 	newHud, err := newHUD()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	game := newSimulation(seed)
+	game.hud = newHud
+	game.ball.loadSounds()
+	game.mode = mode
+	game.local = true
+	game.player.controller = newInputController(readPlayer1)
+	if mode == twoPlayers {
+		game.enemy.controller = newInputController(readPlayer2)
+	}
+
+	return game
+}
+
+// newSimulation creates a game without HUD, sounds or keyboard input.
+// The enemy is controlled by the AI (normal difficulty), and the player's paddle has no controller.
+// It can be stepped by calling Update, without ever opening a window.
+func newSimulation(seed int64) *Game {
 	// Create the game
 	game := &Game{
-		state:  firstService,
-		ball:   newBall(),
-		player: newPlayer(),
-		enemy:  newEnemy(),
-		hud:    newHud,
+		seed:       seed,
+		rng:        newRand(seed),
+		state:      firstService,
+		ball:       newBall(),
+		player:     newPlayer(),
+		enemy:      newEnemy(),
+		difficulty: defaultAIProfile,
+		rules:      defaultMatchRules,
 	}
 
+	game.enemy.controller = newAIController(game.difficulty)
+
 	// Add the objects to the objects slice
-	game.objects = append(game.objects, game.ball, game.player, game.enemy)
+	game.updateObjects()
 
 	return game
 }
@@ -69,96 +138,96 @@ func newGame() *Game {
 func (g *Game) startNewRound() {
 	g.volleyCount = 0 // reset the volley count
 
-	// Stop the ball
+	// Stop the ball, and take the extra balls off the court
 	g.ball.velocity.X = 0
 	g.ball.velocity.Y = 0
+	g.ball.spin = 0
+	g.ball.hitter = noSide
+	g.ball.held = 0
+	g.removeExtraBalls()
 
 	// Place the ball in the center of the screen
-	g.ball.position.Center(halfGameScreenWidth, randInt(20, screenHeight-20))
+	y := g.rng.randInt(20, screenHeight-20)
+	g.ball.moveTo(float64(halfGameScreenWidth-g.ball.position.Width/2), float64(y-g.ball.position.Height/2))
 
 	// Serve the ball to a random side, with lower speed,
-	g.ball.setInitialVelocity()
+	g.ball.setInitialVelocity(g.rng)
 
 }
 
-// checkWinCondition checks if either the player or enemy has won the game.
+// checkWinCondition checks if the point that was just scored won a set or the match,
+// otherwise it starts a new round (and a new set if one was won).
 func (g *Game) checkWinCondition() {
-	if g.score.player == pointsToWin {
-		g.state = gameOver
-	} else if g.score.enemy == pointsToWin {
+	s := &g.score
+	setOver := true
+	switch {
+	case g.rules.wonSet(s.player, s.enemy):
+		s.playerSets++
+	case g.rules.wonSet(s.enemy, s.player):
+		s.enemySets++
+	default:
+		setOver = false
+	}
+
+	if g.isGameOver() {
+		// the points of the last set are kept, to be shown with the result
 		g.state = gameOver
-	} else {
-		g.startNewRound()
+		return
 	}
+	if setOver {
+		s.player, s.enemy = 0, 0
+	}
+	g.startNewRound()
 }
 
+// isGameOver returns true if a player has won enough sets, or is ahead when the time is up
 func (g *Game) isGameOver() bool {
-	return g.score.player == pointsToWin || g.score.enemy == pointsToWin
+	setsToWin := g.rules.setsToWin()
+	return g.score.playerSets >= setsToWin || g.score.enemySets >= setsToWin || (g.timeUp() && g.lead() != 0)
 }
 
-// handleEnemyAttack handles the enemy's AI paddle movement.
-func (g *Game) handleEnemyAttack() {
-	// Calculate in which Y there will be collision
-	// slope of the ball's trajectory
-	slope := g.ball.velocity.Y / g.ball.velocity.X
-
-	// Y-intercept of the ball's trajectory
-	yIntercept := float64(g.ball.position.Y) - slope*float64(g.ball.position.X)
-
-	// predict the Y position of the ball when it reaches the center of the paddle
-	predictedY := slope*float64(g.enemy.paddle.position.X) + yIntercept
-
-	// Check if the paddle is already at the predicted Y position
-	// taking into account the paddle's speed (to avoid jittering)
-	offset := 10
-	if g.enemy.paddle.position.CenterX() >= int(predictedY)-offset && g.enemy.paddle.position.CenterX() <= int(predictedY)+offset {
-		// stop moving
-		g.enemy.paddle.velocity.Y = 0
-		return
-	} else {
-		// If the paddle is not at the predicted Y position, move it towards the predicted Y position
-		// If the paddle is lower than the predicted Y position, move it up
-		if g.enemy.paddle.position.CenterY() > int(predictedY) {
-			// if the distance is less than the paddle's speed, stop
-			if g.enemy.paddle.position.CenterY()-int(predictedY) < int(g.enemy.paddle.speed) {
-				g.enemy.paddle.velocity.Y = 0
-				return
-			}
-			// move it up
-			g.enemy.paddle.velocity.Y = randFloat(-g.enemy.paddle.speed/2, -g.enemy.paddle.speed)
-		}
+// matchDuration returns how long the match has been played (the time spent paused is not counted)
+func (g *Game) matchDuration() time.Duration {
+	return time.Duration(g.frame) * tickDuration
+}
 
-		// If the paddle is higher than the predicted Y position, move it down
-		if g.enemy.paddle.position.CenterY() < int(predictedY) {
-			// if the distance is less than the paddle's speed, stop
-			if int(predictedY)-g.enemy.paddle.position.CenterY() < int(g.enemy.paddle.speed) {
-				g.enemy.paddle.velocity.Y = 0
-				return
-			}
-			// move it down
-			g.enemy.paddle.velocity.Y = randFloat(g.enemy.paddle.speed/2, g.enemy.paddle.speed)
-		}
+// names returns how the sides are called on the screen, depending on the game mode
+func (g *Game) names() (enemy, player string) {
+	if g.mode == twoPlayers {
+		return "PLAYER 2", "PLAYER 1"
 	}
+	return "CPU", "PLAYER 1"
 }
 
 // handleBallCollision handles the collision of the ball with the paddles only.
-func (g *Game) handlePaddleCollision(holder PaddleHolder) error {
-	if err := g.ball.playSound("paddle"); err != nil {
+func (g *Game) handlePaddleCollision(ball *Ball, holder PaddleHolder) error {
+	if err := ball.playSound("paddle"); err != nil {
 		return err
 	}
 
 	g.volleyCount++
-	g.ball.accelerate(1)
+	if g.volleyCount > g.longestVolley {
+		g.longestVolley = g.volleyCount
+	}
+	ball.accelerate(1)
 
 	switch holder.GetPaddle() {
 	case g.player.paddle:
 		g.turn = computer
-		g.ball.position.Right(g.player.paddle.position.Left())
-		g.player.bounce(g.ball, g.volleyCount)
+		ball.moveTo(g.player.paddle.pos.X-float64(ball.position.Width), ball.pos.Y)
+		ball.bounce(g.player.paddle, g.volleyCount)
+		ball.hitter = playerSide
 	case g.enemy.paddle:
 		g.turn = user
-		g.ball.position.Left(g.enemy.paddle.position.Right())
-		g.enemy.bounce(g.ball, g.volleyCount)
+		ball.moveTo(g.enemy.paddle.pos.X+float64(g.enemy.paddle.position.Width), ball.pos.Y)
+		ball.bounce(g.enemy.paddle, g.volleyCount)
+		ball.hitter = enemySide
+	}
+	if g.rules.Spin {
+		ball.spinOff(holder.GetPaddle())
+	}
+	if g.rules.PowerUps {
+		g.powerUpHit(ball, holder.GetPaddle())
 	}
 
 	return nil
@@ -172,7 +241,7 @@ func (g *Game) handlePaddleCollision(holder PaddleHolder) error {
 func (g *Game) handleFirstService() error {
 	if g.ball.velocity.X == 0 && g.ball.velocity.Y == 0 {
 		g.volleyCount = 0
-		g.ball.setInitialVelocity()
+		g.ball.setInitialVelocity(g.rng)
 		g.state = playing
 	}
 
@@ -184,7 +253,7 @@ func (g *Game) handleFirstService() error {
 //  2. If the ball goes off the right side of the screen, the enemy scores.
 //  3. If either player scores, the game checks if the game is over.
 func (g *Game) handleScore() error {
-	if g.ball.position.Left() <= 0 {
+	if g.ball.position.Left() <= 0 && !g.shieldBlocks(g.ball) {
 		if err := g.ball.playSound("score"); err != nil {
 			return err
 		}
@@ -192,12 +261,15 @@ func (g *Game) handleScore() error {
 		g.checkWinCondition()
 	}
 
-	if g.ball.position.Right() >= screenWidth {
+	if g.ball.position.Right() >= screenWidth && !g.shieldBlocks(g.ball) {
 		if err := g.ball.playSound("score"); err != nil {
 			return err
 		}
 		g.score.enemy++
 		g.checkWinCondition()
 	}
+	if g.rules.PowerUps {
+		return g.handleExtraBallScores()
+	}
 	return nil
 }
@@ -5,15 +5,30 @@ import (
 	"image/color"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 func (g *Game) Update() error {
-	switch g.state {
-	case paused:
+	if g.handlePause() {
+		return nil
+	}
+
+	// The pause menu does not count as frames
+	if g.state == paused {
+		g.menu.Update()
 		return nil
+	}
+
+	g.frame++
+
+	// When the time is up, the match ends in the middle of the point, unless it is a tie
+	if g.state == firstService || g.state == playing {
+		g.checkTimeLimit()
+	}
 
+	switch g.state {
 	case gameOver:
 		return nil
 
@@ -29,25 +44,11 @@ func (g *Game) Update() error {
 			g.turn = user
 		}
 
-		// Make the ball speed up after the first 4 volleys
+		// Make the balls speed up after the first 4 volleys
 		if g.volleyCount < 4 {
-			g.ball.normalizeBallSpeed()
-		}
-
-		// Collision logic has 3 parts:
-		// 	1. Check if the ball is colliding with the player's paddle
-		// 	2. Check if the ball is colliding with the enemy's paddle
-		// 	3. Check if the ball is colliding with the top or bottom wall
-		if g.ball.position.CollidesWith(g.player.paddle.position) {
-			if err := g.handlePaddleCollision(g.player.paddle); err != nil {
-				return err
-			}
-		} else if g.ball.position.CollidesWith(g.enemy.paddle.position) {
-			if err := g.handlePaddleCollision(g.enemy.paddle); err != nil {
-				return err
+			for _, ball := range g.balls() {
+				ball.normalizeBallSpeed(g.ballSpeed())
 			}
-		} else {
-			g.ball.handleBallWallCollision()
 		}
 
 		// If someone scores,
@@ -57,19 +58,30 @@ func (g *Game) Update() error {
 			return err
 		}
 
-		// AI logic for the enemy has two parts:
-		// 	1. If the enemy is not serving, it will patrol the screen
-		// 	2. If the enemy is serving, it will attack (meaning, it will move towards the ball)
-		if g.turn == computer {
-			g.handleEnemyAttack()
-		} else {
-			g.enemy.patrol()
+		// Let the controllers (keyboard, AI, etc) set the velocity they want for the paddles,
+		// and get the paddles going as fast as they can
+		if g.player.controller != nil {
+			g.player.controller.Control(g, g.player.paddle)
+		}
+		if g.enemy.controller != nil {
+			g.enemy.controller.Control(g, g.enemy.paddle)
 		}
+		g.drive(g.player.paddle)
+		g.drive(g.enemy.paddle)
 
-		// Lastly, update the ball, player and enemy positions
+		// Lastly, update the ball, player and enemy positions,
+		// and move the balls against where the paddles are now
 		for _, obj := range g.objects {
 			obj.Update()
 		}
+		for _, ball := range g.balls() {
+			if err := g.moveBall(ball); err != nil {
+				return err
+			}
+		}
+		if g.rules.PowerUps {
+			g.updatePowerUps()
+		}
 	}
 
 	return nil
@@ -88,22 +100,42 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		obj.Draw(screen)
 	}
 
-	// draw score
+	// draw the name of each side and its score
+	enemyName, playerName := g.names()
+	text.Draw(screen, enemyName, g.hud.ResultDisplayFont, halfGameScreenWidth-360, 30, color.White)
+	text.Draw(screen, playerName, g.hud.ResultDisplayFont, halfGameScreenWidth+360-75, 30, color.White)
 	text.Draw(screen, fmt.Sprintf("%d", g.score.enemy), g.hud.ScoreDisplayFont, halfGameScreenWidth-360, 120, color.White)
 	text.Draw(screen, fmt.Sprintf("%d", g.score.player), g.hud.ScoreDisplayFont, halfGameScreenWidth+360-75, 120, color.White)
 
+	// draw the sets won by each side, and the time left
+	if g.rules.Sets > 1 {
+		text.Draw(screen, fmt.Sprintf("SETS %d", g.score.enemySets), g.hud.ResultDisplayFont, halfGameScreenWidth-360, 160, color.White)
+		text.Draw(screen, fmt.Sprintf("SETS %d", g.score.playerSets), g.hud.ResultDisplayFont, halfGameScreenWidth+360-75, 160, color.White)
+	}
+	if g.rules.TimeLimit > 0 && g.state != gameOver {
+		clock := formatDuration(g.timeLeft())
+		if g.suddenDeath() {
+			clock = "SUDDEN DEATH"
+		}
+		g.hud.drawCentered(screen, clock, g.hud.ResultDisplayFont, 30)
+	}
+	if g.rules.PowerUps {
+		g.drawEffects(screen)
+	}
+
 	if g.state == paused {
-		text.Draw(screen, "PAUSED", g.hud.ScoreDisplayFont, halfGameScreenWidth-100, halfGameScreenHeight-100, color.White)
+		g.menu.Draw(screen, g.hud)
 	}
 
 	if g.state == gameOver {
-		if g.score.player > g.score.enemy {
-			text.Draw(screen, "WINNER", g.hud.ResultDisplayFont, halfGameScreenWidth+450, halfGameScreenHeight, color.White)
-			text.Draw(screen, "LOSER", g.hud.ResultDisplayFont, halfGameScreenWidth-450, halfGameScreenHeight, color.White)
-		} else {
-			text.Draw(screen, "WINNER", g.hud.ResultDisplayFont, halfGameScreenWidth-450, halfGameScreenHeight, color.White)
-			text.Draw(screen, "LOSER", g.hud.ResultDisplayFont, halfGameScreenWidth+350, halfGameScreenHeight, color.White)
-		}
+		g.hud.drawMessage(screen, g.winner()+" WINS", halfGameScreenHeight)
+	}
+
+	// draw debug information (the rally is the number of the point being played)
+	if g.debug {
+		g.drawPrediction(screen)
+		rally := g.score.player + g.score.enemy + 1
+		ebitenutil.DebugPrint(screen, fmt.Sprintf("seed: %d\nrally: %d\nvolley: %d\ndifficulty: %s", g.seed, rally, g.volleyCount, g.difficulty.Name))
 	}
 }
 
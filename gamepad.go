@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// maxGamepads is the number of players who can play with a gamepad
+const maxGamepads = 2
+
+// GamepadConfig is how the gamepads control the paddles
+type GamepadConfig struct {
+	// The distance from the center of the left stick (from 0 to 1) under which it is considered centered,
+	// because worn sticks rarely go back exactly to the center
+	DeadZone float64 `json:"deadZone"`
+}
+
+// defaultGamepadConfig returns the gamepad settings the game was designed with
+func defaultGamepadConfig() GamepadConfig {
+	return GamepadConfig{DeadZone: 0.2}
+}
+
+// validate returns an error if the gamepad settings make no sense
+func (c GamepadConfig) validate() error {
+	if c.DeadZone < 0 || c.DeadZone >= 1 {
+		return fmt.Errorf("gamepad.deadZone must be between 0 and 1 (excluded), got %v", c.DeadZone)
+	}
+	return nil
+}
+
+// GamepadSlots assigns the gamepads with a standard layout to the players, in the order they are connected:
+// the first one to player 1 (the right paddle) and the second one to player 2 (the left paddle).
+// A gamepad can be plugged in or out at any time: a player whose gamepad is unplugged
+// gets the next gamepad plugged in, and the other player keeps theirs.
+type GamepadSlots struct {
+	// The gamepad of each player, if connected is true
+	ids       [maxGamepads]ebiten.GamepadID
+	connected [maxGamepads]bool
+
+	// Whether a gamepad of a player was unplugged since the last call of unplugged
+	lost bool
+}
+
+// gamepads are the gamepads of the players
+var gamepads GamepadSlots
+
+// update frees the slots of the gamepads that were unplugged, and assigns the new gamepads to the free slots
+func (s *GamepadSlots) update() {
+	ids := ebiten.AppendGamepadIDs(nil)
+	for slot := range s.ids {
+		if s.connected[slot] && !containsGamepad(ids, s.ids[slot]) {
+			log.Printf("gamepad of player %d unplugged", slot+1)
+			s.connected[slot] = false
+			s.lost = true
+		}
+	}
+	for _, id := range ids {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) || s.assigned(id) {
+			continue
+		}
+		for slot := range s.ids {
+			if !s.connected[slot] {
+				log.Printf("gamepad %q plugged in for player %d", ebiten.GamepadName(id), slot+1)
+				s.ids[slot], s.connected[slot] = id, true
+				break
+			}
+		}
+	}
+}
+
+// assigned returns true if the gamepad belongs to a player
+func (s *GamepadSlots) assigned(id ebiten.GamepadID) bool {
+	for slot := range s.ids {
+		if s.connected[slot] && s.ids[slot] == id {
+			return true
+		}
+	}
+	return false
+}
+
+// gamepad returns the gamepad of the player in the slot, if they have one
+func (s *GamepadSlots) gamepad(slot int) (ebiten.GamepadID, bool) {
+	s.update()
+	return s.ids[slot], s.connected[slot]
+}
+
+// unplugged returns true if the gamepad of a player was unplugged since the last time it was called
+func (s *GamepadSlots) unplugged() bool {
+	s.update()
+	lost := s.lost
+	s.lost = false
+	return lost
+}
+
+// containsGamepad returns true if id is one of the ids
+func containsGamepad(ids []ebiten.GamepadID, id ebiten.GamepadID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// readStick returns the analog level (see PaddleInput.Analog) of the left stick of the gamepad of the player in the slot,
+// 0 if they have no gamepad
+func readStick(slot int) int {
+	id, ok := gamepads.gamepad(slot)
+	if !ok {
+		return 0
+	}
+	return stickLevel(ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical), cfg.Gamepad.DeadZone)
+}
+
+// stickLevel returns the analog level (see PaddleInput.Analog) of a stick at the position v, from -1 (up) to 1 (down).
+// The dead zone around the center is ignored, and the rest of the way is spread over all the levels.
+func stickLevel(v, deadZone float64) int {
+	if math.Abs(v) <= deadZone {
+		return 0
+	}
+	v = math.Copysign((math.Min(1, math.Abs(v))-deadZone)/(1-deadZone), v)
+	return int(math.Round(v * analogSteps))
+}
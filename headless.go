@@ -0,0 +1,77 @@
+package main
+
+// HeadlessConfig configures a match that is simulated without a window, audio or keyboard
+type HeadlessConfig struct {
+	// Seed of the random number generator
+	Seed int64
+
+	// MaxFrames stops the simulation after this many updates (0 means no limit)
+	MaxFrames int
+
+	// The controllers of the player's paddle (right side) and the enemy's paddle (left side).
+	// A paddle without a controller never moves.
+	Player Controller
+	Enemy  Controller
+
+	// The difficulty of the paddles controlled by the AI (normal if it is not set)
+	Difficulty AIProfile
+
+	// The rules of the match (classic if they are not set)
+	Rules MatchRules
+
+	// Record a replay of the match
+	Record bool
+}
+
+// HeadlessResult is the outcome of a headless match
+type HeadlessResult struct {
+	// The score when the simulation stopped
+	Score Score
+
+	// The number of updates that were simulated
+	Frames int
+
+	// Finished is true if the match reached the game over state
+	Finished bool
+
+	// The replay of the match (nil unless it was recorded)
+	Replay *Replay
+}
+
+// RunHeadless steps the game state machine (firstService, playing, gameOver) at full speed
+// until the match is over or the frame limit is reached, and reports the final score.
+func RunHeadless(config HeadlessConfig) (HeadlessResult, error) {
+	g := newSimulation(config.Seed)
+	g.player.controller = config.Player
+	g.enemy.controller = config.Enemy
+	if config.Difficulty.Name != "" {
+		g.setDifficulty(config.Difficulty)
+	}
+	if config.Rules.Name != "" {
+		g.rules = config.Rules
+	}
+
+	var result HeadlessResult
+	var game interface{ Update() error } = g
+	if config.Record {
+		recorder := newRecorder(g)
+		result.Replay = recorder.replay
+		game = recorder
+	}
+
+	for config.MaxFrames == 0 || result.Frames < config.MaxFrames {
+		if g.state == gameOver {
+			result.Finished = true
+			break
+		}
+		if err := game.Update(); err != nil {
+			result.Score = g.score
+			return result, err
+		}
+		result.Frames++
+	}
+	result.Finished = g.state == gameOver
+	result.Score = g.score
+
+	return result, nil
+}
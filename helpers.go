@@ -1,6 +1,9 @@
 package main
 
-import "math/rand"
+import (
+	"fmt"
+	"time"
+)
 
 // Vector2D is a struct that stores X and Y values for a position
 type Vector2D struct {
@@ -9,20 +12,26 @@ type Vector2D struct {
 }
 
 // Function that returns randomly either a or b. If a and b are equal, it returns value 'a'.
-func randomChoice(a, b float64) float64 {
+func (r *Rand) randomChoice(a, b float64) float64 {
 	if a == b {
 		return a
 	}
-	if rand.Intn(2) == 0 {
+	if r.Intn(2) == 0 {
 		return a
 	}
 	return b
 }
 
-func randInt(min int, max int) int {
-	return min + rand.Intn(max-min)
+func (r *Rand) randInt(min int, max int) int {
+	return min + r.Intn(max-min)
 }
 
-func randFloat(min float64, max float64) float64 {
-	return min + rand.Float64()*(max-min)
+func (r *Rand) randFloat(min float64, max float64) float64 {
+	return min + r.Float64()*(max-min)
+}
+
+// formatDuration formats a duration as minutes and seconds (e.g. 3:05)
+func formatDuration(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
 }
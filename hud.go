@@ -1,14 +1,20 @@
 package main
 
 import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/examples/resources/fonts"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 )
 
 type HUD struct {
-	ScoreDisplayFont  font.Face
-	ResultDisplayFont font.Face
+	ScoreDisplayFont   font.Face
+	ResultDisplayFont  font.Face
+	MessageDisplayFont font.Face
 }
 
 func newHUD() (*HUD, error) {
@@ -36,8 +42,35 @@ func newHUD() (*HUD, error) {
 		return nil, err
 	}
 
+	messageDisplayFont, err := opentype.NewFace(tt, &opentype.FaceOptions{
+		Size:    36,
+		DPI:     dpi,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return &HUD{
-		ScoreDisplayFont:  scoreDisplayFont,
-		ResultDisplayFont: resultDisplayFont,
+		ScoreDisplayFont:   scoreDisplayFont,
+		ResultDisplayFont:  resultDisplayFont,
+		MessageDisplayFont: messageDisplayFont,
 	}, nil
 }
+
+// drawMessage draws a message horizontally centered on the screen, on a black background,
+// with its baseline at y
+func (h *HUD) drawMessage(screen *ebiten.Image, message string, y int) {
+	bounds := text.BoundString(h.MessageDisplayFont, message)
+	x := halfGameScreenWidth - bounds.Dx()/2
+
+	padding := 20
+	vector.DrawFilledRect(screen, float32(x-padding), float32(y+bounds.Min.Y-padding), float32(bounds.Dx()+2*padding), float32(bounds.Dy()+2*padding), color.Black)
+	h.drawCentered(screen, message, h.MessageDisplayFont, y)
+}
+
+// drawCentered draws a text horizontally centered on the screen, with its baseline at y
+func (h *HUD) drawCentered(screen *ebiten.Image, s string, face font.Face, y int) {
+	bounds := text.BoundString(face, s)
+	text.Draw(screen, s, face, halfGameScreenWidth-bounds.Dx()/2, y, color.White)
+}
@@ -5,22 +5,71 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
-// function to handle user input controlling the paddle up and down
-func (p *Paddle) input() {
-	userMovementSpeed := 15.0 // the speed of the paddle every time the user presses a key
-
-	// Up
-	if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) {
-		p.velocity.Y = p.velocity.Y - userMovementSpeed
-	} else if inpututil.IsKeyJustReleased(ebiten.KeyArrowUp) {
-		p.velocity.Y = p.velocity.Y + userMovementSpeed
+// PaddleInput holds which paddle controls are held down during a single frame
+type PaddleInput struct {
+	Up   bool
+	Down bool
+
+	// The position of an analog control (e.g. the stick of a gamepad), from -analogSteps (all the way up)
+	// to analogSteps (all the way down): the paddle moves at a speed proportional to it
+	Analog int
+}
+
+// analogSteps is the number of speeds of the paddle in each direction with an analog control
+// (the last one is the full speed, the speed of the keys)
+const analogSteps = 7
+
+// level returns how fast the input moves the paddle, from -analogSteps (up at full speed) to analogSteps (down at full speed)
+func (in PaddleInput) level() int {
+	level := in.Analog
+	if in.Up {
+		level -= analogSteps
+	}
+	if in.Down {
+		level += analogSteps
+	}
+	if level < -analogSteps {
+		return -analogSteps
+	}
+	if level > analogSteps {
+		return analogSteps
 	}
+	return level
+}
 
-	// Down
-	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) {
-		p.velocity.Y = p.velocity.Y + userMovementSpeed
-	} else if inpututil.IsKeyJustReleased(ebiten.KeyArrowDown) {
-		p.velocity.Y = p.velocity.Y - userMovementSpeed
+// readPlayer returns an input source that reads the controls of the player in the slot (0 for player 1):
+// the keys and the gamepad buttons bound to up and down (see ControlsConfig), and the left stick of their gamepad
+func readPlayer(slot int) InputSource {
+	return func(_ *Game, _ *Paddle) PaddleInput {
+		bindings := cfg.Controls.player(slot)
+		return PaddleInput{
+			Up:     bindings.Up.pressed(slot),
+			Down:   bindings.Down.pressed(slot),
+			Analog: readStick(slot),
+		}
 	}
+}
+
+// The controls of player 1 and player 2
+var (
+	readPlayer1 = readPlayer(0)
+	readPlayer2 = readPlayer(1)
+)
+
+// gamepadJustPressed returns true if the button was just pressed on any gamepad with a standard layout
+func gamepadJustPressed(button ebiten.StandardGamepadButton) bool {
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) && inpututil.IsStandardGamepadButtonJustPressed(id, button) {
+			return true
+		}
+	}
+	return false
+}
 
+// function to handle user input controlling the paddle up and down, at most at speed.
+// The velocity wanted only depends on the controls held down in the current frame,
+// so that it cannot drift when a press or a release is missed (e.g. when the window loses the focus).
+func (p *Paddle) input(in PaddleInput, speed float64) {
+	// a key is analogSteps steps, the full speed
+	p.target = float64(in.level()) / analogSteps * speed
 }
@@ -1,20 +1,277 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/hajimehoshi/ebiten/v2"
 	"log"
+	"os"
+	"time"
 )
 
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %s [flags]\n  %s [flags] replay <file>\n\nFlags:\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	headless := flag.Bool("headless", false, "simulate a match without a window and print the final score (keyboard controls are played by the follow bot)")
+	maxFrames := flag.Int("frames", 60*60*30, "maximum number of frames to simulate in headless mode (0 means no limit)")
+	seed := flag.Int64("seed", 0, "seed of the random number generator, to reproduce a match (0 picks a random seed)")
+	debug := flag.Bool("debug", false, "show debug information (e.g. the seed) on the screen")
+	record := flag.String("record", "", "record a replay of the match to this file")
+	savePath := flag.String("save", defaultSavePath(), "file where the match in progress is saved when the window is closed, to continue it on the next launch (empty disables it)")
+	modeName := flag.String("mode", "1p", "game mode: 1p (player versus the computer) or 2p (two players, W/S and arrow keys)")
+	left := flag.String("left", "", fmt.Sprintf("controller of the left paddle %v (default: cpu in 1p mode, ws in 2p mode)", controllerNames))
+	right := flag.String("right", "keyboard", fmt.Sprintf("controller of the right paddle %v", controllerNames))
+	host := flag.String("host", "", "host an online match on this address (e.g. :7777) and wait for the other player to join")
+	join := flag.String("join", "", "join the online match hosted at this address (e.g. 192.168.1.2:7777)")
+	inputDelay := flag.Int("input-delay", 2, "online match: number of frames between pressing a key and the paddle moving (hides the network latency)")
+	netcode := flag.String("netcode", "lockstep", "online match: lockstep (wait for the other player) or rollback (predict the other player and correct the mistakes)")
+	rollback := flag.Int("rollback", 8, "online match with rollback netcode: maximum number of frames played ahead of the other player")
+	latency := flag.Duration("net-latency", 0, "online match: simulated delay of the packets sent, to test on a single machine (e.g. 50ms)")
+	jitter := flag.Duration("net-jitter", 0, "online match: simulated random variation of the delay of the packets sent (e.g. 20ms)")
+	loss := flag.Float64("net-loss", 0, "online match: simulated probability of losing a packet sent, from 0 to 1")
+	difficulty := flag.String("difficulty", "", fmt.Sprintf("difficulty of the AI %v, or custom (default: chosen from a menu in 1p mode, otherwise normal)", aiProfileNames))
+	custom := defaultAIProfile
+	custom.Name = "custom"
+	flag.Float64Var(&custom.Speed, "ai-speed", custom.Speed, "custom difficulty: speed of the AI paddle in pixels per frame")
+	flag.Float64Var(&custom.Jitter, "ai-jitter", custom.Jitter, "custom difficulty: random slow down of the AI paddle while attacking, from 0 (none) to 1")
+	flag.IntVar(&custom.ReactionDelay, "ai-reaction", custom.ReactionDelay, "custom difficulty: reaction time of the AI in frames (it sees where the ball was this many frames ago)")
+	flag.Float64Var(&custom.PredictionError, "ai-error", custom.PredictionError, "custom difficulty: maximum error in pixels when the AI predicts the ball position")
+	flag.Float64Var(&custom.VelocityNoise, "ai-noise", custom.VelocityNoise, "custom difficulty: relative error when the AI perceives the ball velocity (e.g. 0.1)")
+	flag.Float64Var(&custom.Fatigue, "ai-fatigue", custom.Fatigue, "custom difficulty: fraction of speed lost (and errors gained) by the AI every volley")
+	flag.Float64Var(&custom.MissChance, "ai-miss", custom.MissChance, "custom difficulty: probability of the AI deliberately missing the ball, from 0 to 1")
+	flag.BoolVar(&custom.WallBounces, "ai-walls", custom.WallBounces, "custom difficulty: the AI accounts for the ball bouncing off the walls")
+	flag.IntVar(&custom.DeadZone, "ai-deadzone", custom.DeadZone, "custom difficulty: distance in pixels at which the AI paddle stops moving")
+	flag.StringVar(&configPath, "config", defaultConfigPath(), "read the tunables of the game from this JSON file, where the controls changed in the menus are saved (the flags below override it, empty to not use a file)")
+	dumpConfig := flag.Bool("dump-config", false, "print the configuration (the default one, or the one of -config with the flags applied) and exit")
+	flag.IntVar(&cfg.Screen.Width, "screen-width", cfg.Screen.Width, "width of the game screen in pixels")
+	flag.IntVar(&cfg.Screen.Height, "screen-height", cfg.Screen.Height, "height of the game screen in pixels")
+	flag.IntVar(&cfg.PointsToWin, "points-to-win", cfg.PointsToWin, "points needed to win with the classic rules")
+	flag.IntVar(&cfg.Ball.Size, "ball-size", cfg.Ball.Size, "width and height of the ball in pixels")
+	flag.Float64Var(&cfg.Ball.MaxSpeed, "ball-speed", cfg.Ball.MaxSpeed, "speed of the ball after the first volleys, in pixels per frame")
+	flag.IntVar(&cfg.Paddle.Width, "paddle-width", cfg.Paddle.Width, "width of the paddles in pixels")
+	flag.IntVar(&cfg.Paddle.Height, "paddle-height", cfg.Paddle.Height, "height of the paddles in pixels")
+	flag.IntVar(&cfg.Paddle.Margin, "paddle-margin", cfg.Paddle.Margin, "distance between the paddles and the edges of the screen in pixels")
+	flag.Float64Var(&cfg.Paddle.Speed, "paddle-speed", cfg.Paddle.Speed, "speed of the paddles controlled by the players, in pixels per frame")
+	flag.Float64Var(&cfg.Spin.Transfer, "spin-transfer", cfg.Spin.Transfer, "spin given to the ball for every pixel per frame of the paddle speed, in degrees per frame")
+	flag.Float64Var(&cfg.Spin.Max, "spin-max", cfg.Spin.Max, "maximum spin of the ball, in degrees per frame")
+	flag.Float64Var(&cfg.Spin.Decay, "spin-decay", cfg.Spin.Decay, "fraction of its spin that the ball keeps every frame")
+	flag.Float64Var(&cfg.Spin.WallGrip, "spin-wall-grip", cfg.Spin.WallGrip, "frames of spin that a wall turns into a change of the bounce angle")
+	flag.Float64Var(&cfg.Momentum.Acceleration, "momentum-acceleration", cfg.Momentum.Acceleration, "speed gained every frame by a paddle with momentum, in pixels per frame per frame")
+	flag.Float64Var(&cfg.Momentum.MaxSpeed, "momentum-max-speed", cfg.Momentum.MaxSpeed, "maximum speed of the paddles with momentum, for the players and the AI, in pixels per frame")
+	flag.Float64Var(&cfg.Momentum.Friction, "momentum-friction", cfg.Momentum.Friction, "speed lost every frame by a paddle with momentum that is not pushed, in pixels per frame per frame")
+	flag.IntVar(&cfg.PowerUps.Interval, "power-up-interval", cfg.PowerUps.Interval, "frames between two power-ups appearing on the court (60 per second)")
+	flag.IntVar(&cfg.PowerUps.Lifetime, "power-up-lifetime", cfg.PowerUps.Lifetime, "frames a power-up stays on the court if it is not collected")
+	flag.IntVar(&cfg.PowerUps.Duration, "power-up-duration", cfg.PowerUps.Duration, "frames the effect of a power-up lasts")
+	flag.IntVar(&cfg.PowerUps.Max, "power-up-max", cfg.PowerUps.Max, "most power-ups on the court at once")
+	flag.Float64Var(&cfg.Gamepad.DeadZone, "gamepad-dead-zone", cfg.Gamepad.DeadZone, "distance from the center of a gamepad stick (from 0 to 1) under which it is considered centered")
+	flag.StringVar(&cfg.Rules, "rules", cfg.Rules, fmt.Sprintf("rules of the match %v, or custom", matchRulesNames))
+	flag.IntVar(&cfg.CustomRules.PointsToWin, "points", cfg.CustomRules.PointsToWin, "custom rules: points to win a set (0 for no limit, with a time limit)")
+	flag.BoolVar(&cfg.CustomRules.WinByTwo, "win-by-two", cfg.CustomRules.WinByTwo, "custom rules: a set must be won by two points")
+	flag.IntVar(&cfg.CustomRules.Sets, "sets", cfg.CustomRules.Sets, "custom rules: number of sets, the match is won by winning more than half of them")
+	spin := flag.Bool("spin", false, "the paddles make the ball spin and curve, with any rules")
+	powerUps := flag.Bool("power-ups", false, "arcade mode: power-ups appear on the court, with any rules")
+	momentum := flag.Bool("momentum", false, "the paddles accelerate and slow down instead of starting and stopping right away, with any rules")
+	flag.TextVar(&cfg.CustomRules.TimeLimit, "time-limit", cfg.CustomRules.TimeLimit, "custom rules: duration of the match, the player ahead wins when the time is up (e.g. 3m, 0 for no limit)")
+	flag.Parse()
+
+	if configPath != "" {
+		// the flags given on the command line are set again on top of the config file
+		overrides := map[string]string{}
+		flag.Visit(func(f *flag.Flag) { overrides[f.Name] = f.Value.String() })
+		loaded, err := loadConfig(configPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && configPath == defaultConfigPath():
+			// the default file is only created when the controls are changed
+			loaded = defaultConfig()
+		case err != nil:
+			log.Fatal(err)
+		}
+		cfg = loaded
+		for name, value := range overrides {
+			if err := flag.Set(name, value); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		log.Fatal(err)
+	}
+	if *dumpConfig {
+		if err := cfg.dump(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	cfg.apply()
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	mode, ok := gameModeNames[*modeName]
+	if !ok {
+		log.Fatalf("unknown game mode %q (expected 1p or 2p)", *modeName)
+	}
+	leftName := *left // empty picks the default of the mode chosen on the title screen
+	if *left == "" {
+		*left = "cpu"
+		if mode == twoPlayers {
+			*left = "ws"
+		}
+	}
+
+	if *headless {
+		// there is no keyboard, mouse or touch screen without a window
+		for _, name := range []*string{left, right} {
+			if *name == "keyboard" || *name == "ws" || *name == "mouse" || *name == "touch" {
+				*name = "follow"
+			}
+		}
+	}
+
+	profile := defaultAIProfile
+	switch *difficulty {
+	case "":
+	case "custom":
+		profile = custom
+	default:
+		var err error
+		if profile, err = lookupAIProfile(*difficulty); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := profile.validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	rules := cfg.CustomRules.rules()
+	if cfg.Rules != "custom" {
+		var err error
+		if rules, err = lookupMatchRules(cfg.Rules); err != nil {
+			log.Fatal(err)
+		}
+	}
+	rules.Spin = *spin
+	rules.Momentum = *momentum
+	rules.MomentumLimits = cfg.Momentum
+	rules.PowerUps = *powerUps
+	if err := rules.validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	enemyController, err := newController(*left, profile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	playerController, err := newController(*right, profile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *headless {
+		result, err := RunHeadless(HeadlessConfig{
+			Seed:       *seed,
+			MaxFrames:  *maxFrames,
+			Player:     playerController,
+			Enemy:      enemyController,
+			Difficulty: profile,
+			Rules:      rules,
+			Record:     *record != "",
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("seed: %d, rules: %s, score: %s, sets: %s, frames: %d, finished: %t\n",
+			*seed, rules.Name, result.Score, result.Score.Sets(), result.Frames, result.Finished)
+		if result.Replay != nil {
+			if err := result.Replay.Save(*record); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
+
 	// Configure the game window
 	ebiten.SetWindowSize(screenWidth, screenHeight)
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeDisabled)
 	ebiten.SetWindowTitle("Pong")
 	ebiten.SetFullscreen(false)
 
-	game := newGame()
+	if *host != "" || *join != "" {
+		config := NetConfig{InputDelay: *inputDelay, Latency: *latency, Jitter: *jitter, Loss: *loss}
+		switch *netcode {
+		case "lockstep":
+		case "rollback":
+			config.Rollback = *rollback
+		default:
+			log.Fatalf("unknown netcode %q (expected lockstep or rollback)", *netcode)
+		}
+		if config.InputDelay < 0 || config.InputDelay > 255 {
+			log.Fatalf("input delay must be between 0 and 255, got %d", config.InputDelay)
+		}
+		if config.Rollback < 0 || config.Rollback > 255 {
+			log.Fatalf("rollback must be between 0 and 255 frames, got %d", config.Rollback)
+		}
+
+		var session *NetSession
+		var err error
+		if *host != "" {
+			session, err = hostNetSession(*host, *seed, config)
+		} else {
+			session, err = joinNetSession(*join, config)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = ebiten.RunGame(session)
+		session.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "replay" {
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		replay, err := loadReplay(flag.Arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		// the replay is played back with the configuration it was recorded with
+		replay.Config.apply()
+		ebiten.SetWindowSize(screenWidth, screenHeight)
+		viewer := newReplayViewer(replay)
+		viewer.game.debug = *debug
+		if err := ebiten.RunGame(viewer); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	if err := ebiten.RunGame(game); err != nil {
+	app, err := newApp(MatchConfig{
+		Seed:             *seed,
+		Mode:             mode,
+		Left:             leftName,
+		Right:            *right,
+		Difficulty:       profile,
+		DifficultyChosen: *difficulty != "",
+		Rules:            rules,
+		Debug:            *debug,
+		RecordPath:       *record,
+		SavePath:         *savePath,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = ebiten.RunGame(app.scenes)
+	app.exit()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
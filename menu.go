@@ -0,0 +1,118 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// MenuItem is an entry of a menu, that runs its action when it is chosen
+type MenuItem struct {
+	label  string
+	action func()
+}
+
+// Menu is a list of items, navigated with the up and down controls of both players (the arrow keys, W and S,
+// or the D-pad of a gamepad by default) and chosen with serve (enter, space or the bottom face button), or clicked or tapped
+type Menu struct {
+	// The title shown above the items
+	title string
+
+	// Lines of text shown between the title and the items (e.g. a summary)
+	info []string
+
+	// The entries of the menu
+	items []MenuItem
+
+	// The index of the highlighted item
+	selected int
+
+	// Reads the input instead of the menu while it is set (e.g. to wait for a key to bind)
+	listen func()
+}
+
+// newMenu creates a menu with the first item highlighted
+func newMenu(title string, items ...MenuItem) *Menu {
+	return &Menu{title: title, items: items}
+}
+
+// Update moves the highlight and runs the action of the chosen item
+func (m *Menu) Update() {
+	if m.listen != nil {
+		m.listen()
+		return
+	}
+	switch {
+	case cfg.Controls.Player1.Up.justPressed() || cfg.Controls.Player2.Up.justPressed():
+		m.selected = (m.selected + len(m.items) - 1) % len(m.items)
+	case cfg.Controls.Player1.Down.justPressed() || cfg.Controls.Player2.Down.justPressed():
+		m.selected = (m.selected + 1) % len(m.items)
+	case cfg.Controls.Serve.justPressed():
+		m.choose()
+	default:
+		if x, y, ok := pointerJustPressed(); ok {
+			if i, ok := m.itemAt(x, y); ok {
+				m.selected = i
+				m.choose()
+			}
+		}
+	}
+}
+
+// choose runs the action of the highlighted item
+func (m *Menu) choose() {
+	if action := m.items[m.selected].action; action != nil {
+		action()
+	}
+}
+
+// menuLineHeight is the height of a line of text of a menu
+const menuLineHeight = 40
+
+// layout returns where the menu is drawn: the top and the height of its background,
+// and the Y position of the baseline of its first item
+func (m *Menu) layout() (top, height, itemsY int) {
+	height = 120 + menuLineHeight*(len(m.info)+len(m.items))
+	if len(m.info) > 0 {
+		height += menuLineHeight / 2 // space between the info and the items
+	}
+	top = halfGameScreenHeight - height/2
+	itemsY = top + 120 + menuLineHeight*len(m.info)
+	if len(m.info) > 0 {
+		itemsY += menuLineHeight / 2
+	}
+	return top, height, itemsY
+}
+
+// itemAt returns the index of the item at the position (x, y) of the screen, if there is one
+func (m *Menu) itemAt(x, y int) (int, bool) {
+	top, height, itemsY := m.layout()
+	if x < halfGameScreenWidth-400 || x >= halfGameScreenWidth+400 || y < top || y >= top+height {
+		return 0, false
+	}
+	// a line goes from a little below the baseline of the line above to a little below its own baseline
+	i := (y - (itemsY - menuLineHeight + 10)) / menuLineHeight
+	if y < itemsY-menuLineHeight+10 || i >= len(m.items) {
+		return 0, false
+	}
+	return i, true
+}
+
+// Draw draws the menu in the middle of the screen, on a black background
+func (m *Menu) Draw(screen *ebiten.Image, hud *HUD) {
+	top, height, itemsY := m.layout()
+	vector.DrawFilledRect(screen, float32(halfGameScreenWidth-400), float32(top), 800, float32(height), color.Black)
+
+	hud.drawCentered(screen, m.title, hud.MessageDisplayFont, top+70)
+	for i, line := range m.info {
+		hud.drawCentered(screen, line, hud.ResultDisplayFont, top+120+menuLineHeight*i)
+	}
+	for i, item := range m.items {
+		label := item.label
+		if i == m.selected {
+			label = "> " + label + " <"
+		}
+		hud.drawCentered(screen, label, hud.ResultDisplayFont, itemsY+menuLineHeight*i)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// MomentumConfig is how the paddles move, when the rules of the match give them momentum (see MatchRules.MomentumLimits).
+// The controllers then only choose the velocity they want (see Paddle.target): the paddle speeds up towards it
+// as fast as it can accelerate, and slows down by friction when it is not pushed.
+// The limits are the same for both paddles, whoever controls them.
+type MomentumConfig struct {
+	// The speed gained every frame while the paddle is pushed, in pixels per frame per frame
+	Acceleration float64 `json:"acceleration"`
+
+	// The maximum speed of the paddles, in pixels per frame
+	MaxSpeed float64 `json:"maxSpeed"`
+
+	// The speed lost every frame while the paddle is not pushed (or pushed slower than it goes),
+	// in pixels per frame per frame. A paddle pushed the other way loses both the friction and the acceleration.
+	Friction float64 `json:"friction"`
+}
+
+// defaultMomentumConfig returns the momentum the game was designed with
+func defaultMomentumConfig() MomentumConfig {
+	return MomentumConfig{Acceleration: 1.2, MaxSpeed: 15, Friction: 1.5}
+}
+
+// validate returns an error if the momentum makes no sense
+func (m MomentumConfig) validate() error {
+	switch {
+	case m.Acceleration <= 0:
+		return fmt.Errorf("momentum.acceleration must be positive, got %v", m.Acceleration)
+	case m.MaxSpeed <= 0:
+		return fmt.Errorf("momentum.maxSpeed must be positive, got %v", m.MaxSpeed)
+	case m.Friction <= 0:
+		return fmt.Errorf("momentum.friction must be positive, got %v", m.Friction)
+	}
+	return nil
+}
+
+// paddleSpeed returns the speed of a paddle pushed all the way by a player
+func (g *Game) paddleSpeed() float64 {
+	if g.rules.Momentum {
+		return g.rules.MomentumLimits.MaxSpeed
+	}
+	return cfg.Paddle.Speed
+}
+
+// drive sets the velocity of the paddle p from the velocity its controller wants:
+// right away, unless the paddles have momentum
+func (g *Game) drive(p *Paddle) {
+	if !g.rules.Momentum {
+		p.velocity.Y = p.target
+		return
+	}
+	p.velocity.Y = accelerate(p.velocity.Y, p.target, g.rules.MomentumLimits)
+}
+
+// accelerate returns the velocity of a paddle going at velocity after a frame of going towards target,
+// within the limits of m
+func accelerate(velocity, target float64, m MomentumConfig) float64 {
+	target = math.Max(-m.MaxSpeed, math.Min(m.MaxSpeed, target))
+
+	var step float64
+	switch {
+	case velocity != 0 && target != 0 && (velocity < 0) != (target < 0):
+		// pushed the other way
+		step = m.Acceleration + m.Friction
+	case math.Abs(target) > math.Abs(velocity):
+		step = m.Acceleration
+	default:
+		step = m.Friction
+	}
+
+	if math.Abs(target-velocity) <= step {
+		return target
+	}
+	return velocity + math.Copysign(step, target-velocity)
+}
+
+// brakingDistance returns how far the paddle p goes before it stops, if it is not pushed anymore
+// (0 if the paddles stop right away, without momentum)
+func (g *Game) brakingDistance(p *Paddle) float64 {
+	if !g.rules.Momentum {
+		return 0
+	}
+	// the speed goes down by the friction every frame, the paddle moves by the speed left
+	friction := g.rules.MomentumLimits.Friction
+	speed := math.Abs(p.velocity.Y)
+	frames := math.Floor(speed / friction)
+	return frames*speed - friction*frames*(frames+1)/2
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+// TestMomentumLimitsOfTheRules checks that the paddles move within the momentum limits of the rules of the match
+func TestMomentumLimitsOfTheRules(t *testing.T) {
+	useConfig(t, defaultConfig())
+
+	g := newSimulation(1)
+	g.rules.Momentum = true
+	g.rules.MomentumLimits = MomentumConfig{Acceleration: 1, MaxSpeed: 5, Friction: 0.5}
+	p := g.player.paddle
+
+	p.target = cfg.Paddle.Speed
+	for i, want := range []float64{1, 2, 3, 4, 5, 5} {
+		g.drive(p)
+		if p.velocity.Y != want {
+			t.Fatalf("pushed for %d frames, the paddle goes at %v, expected %v", i+1, p.velocity.Y, want)
+		}
+	}
+	if distance := g.brakingDistance(p); distance != 22.5 {
+		t.Errorf("braking distance %v, expected 22.5", distance)
+	}
+
+	p.target = 0
+	for i, want := range []float64{4.5, 4, 3.5} {
+		g.drive(p)
+		if p.velocity.Y != want {
+			t.Fatalf("released for %d frames, the paddle goes at %v, expected %v", i+1, p.velocity.Y, want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// netProtocolVersion is the version of the network protocol, both players must use the same one.
+// Versions 3 to 6 have no new message, but the physics or the inputs changed (a game of an older version would desync).
+// Version 7 checks that both players use the same configuration, and version 8 bounces the ball at exact angles.
+const netProtocolVersion = 8
+
+// The types of the messages exchanged between the two players
+const (
+	msgHello   byte = iota + 1 // join -> host: asks to join (protocol version, config hash)
+	msgWelcome                 // host -> join: accepts the join (session, seed, input delay, rollback and config hash)
+	msgInput                   // both ways: the inputs of the sender and the last input received from the peer
+	msgPing                    // both ways: asks for a pong (timestamp)
+	msgPong                    // both ways: answers a ping (the timestamp of the ping)
+	msgBye                     // both ways: the player left the match
+	msgReject                  // host -> join: refuses the join (reason, protocol version of the host)
+)
+
+// The reasons of the host to refuse a player who asks to join (see msgReject)
+const (
+	rejectVersion byte = iota + 1 // the protocol versions differ
+	rejectConfig                  // the configurations differ
+)
+
+// maxInputsPerPacket limits how many inputs are (re)sent in a single packet
+const maxInputsPerPacket = 120
+
+// netPacket is a message received from the network
+type netPacket struct {
+	data []byte
+	from *net.UDPAddr
+}
+
+// netPeer is a UDP socket used to talk to the other player.
+// The packets are received in the background and handed over to the game loop through a channel.
+// To test the game on a single machine (loopback), it can simulate a bad network:
+// the packets it sends are delayed by latency plus a random jitter, and some are lost.
+type netPeer struct {
+	conn *net.UDPConn
+
+	// The peer connected in memory instead of through the socket (see pipe), and the address this one has for it
+	pipe *netPeer
+	addr *net.UDPAddr
+
+	// The address of the other player (nil for the host until someone joins)
+	remote *net.UDPAddr
+
+	// The packets received in the background
+	packets chan netPacket
+
+	// The simulated delay of the packets sent, and its random variation (packets can arrive out of order)
+	latency, jitter time.Duration
+
+	// The simulated probability to lose a packet sent, from 0 to 1
+	loss float64
+}
+
+// listenPeer creates a peer that waits for the other player on the given address (host)
+func listenPeer(address string) (*netPeer, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return startPeer(conn, nil), nil
+}
+
+// dialPeer creates a peer that talks to the other player at the given address (join)
+func dialPeer(address string) (*netPeer, error) {
+	remote, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	return startPeer(conn, remote), nil
+}
+
+// startPeer starts receiving packets in the background
+func startPeer(conn *net.UDPConn, remote *net.UDPAddr) *netPeer {
+	p := &netPeer{conn: conn, remote: remote, packets: make(chan netPacket, 256)}
+	go p.receive()
+	return p
+}
+
+// receive reads the packets from the socket until it is closed
+func (p *netPeer) receive() {
+	buf := make([]byte, 2048)
+	for {
+		n, from, err := p.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				close(p.packets)
+				return
+			}
+			continue
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		select {
+		case p.packets <- netPacket{data: data, from: from}:
+		default:
+			// the game loop is not keeping up, drop the packet (inputs are sent again anyway)
+		}
+	}
+}
+
+// send sends a message to the other player, if its address is known
+func (p *netPeer) send(msg []byte) {
+	p.sendTo(p.remote, msg)
+}
+
+// sendTo sends a message to the given address (e.g. a player who cannot join)
+func (p *netPeer) sendTo(remote *net.UDPAddr, msg []byte) {
+	if remote == nil {
+		return
+	}
+	if p.loss > 0 && rand.Float64() < p.loss {
+		return
+	}
+
+	write := func() {
+		if p.pipe != nil {
+			select {
+			case p.pipe.packets <- netPacket{data: msg, from: p.addr}:
+			default:
+			}
+			return
+		}
+		if _, err := p.conn.WriteToUDP(msg, remote); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("network: %v", err)
+		}
+	}
+	delay := p.latency
+	if p.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(p.jitter)))
+	}
+	if delay > 0 {
+		time.AfterFunc(delay, write)
+		return
+	}
+	write()
+}
+
+// close closes the socket
+func (p *netPeer) close() error {
+	if p.conn == nil {
+		return nil
+	}
+	return p.conn.Close()
+}
+
+// pipe creates two peers connected in memory, without sockets: the host and the player who joins it
+func pipe() (host, join *netPeer) {
+	host = &netPeer{addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7777}, packets: make(chan netPacket, 256)}
+	join = &netPeer{addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7778}, packets: make(chan netPacket, 256)}
+	host.pipe, join.pipe = join, host
+	join.remote = host.addr
+	return host, join
+}
+
+// netWriter builds a message
+type netWriter struct {
+	buf []byte
+}
+
+// newMessage starts a message of the given type for the session
+func newMessage(kind byte, session uint32) *netWriter {
+	w := &netWriter{buf: []byte{kind}}
+	return w.uint32(session)
+}
+
+func (w *netWriter) byte(v byte) *netWriter {
+	w.buf = append(w.buf, v)
+	return w
+}
+
+func (w *netWriter) uint32(v uint32) *netWriter {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+	return w
+}
+
+func (w *netWriter) int64(v int64) *netWriter {
+	w.buf = binary.BigEndian.AppendUint64(w.buf, uint64(v))
+	return w
+}
+
+func (w *netWriter) bytes(v []byte) *netWriter {
+	w.buf = append(w.buf, v...)
+	return w
+}
+
+// netReader reads a message, a short message makes all the following reads fail
+type netReader struct {
+	buf []byte
+	err error
+}
+
+// errShortMessage is returned when a message is truncated
+var errShortMessage = errors.New("network: short message")
+
+// readMessage reads the type and the session of a message
+func readMessage(data []byte) (kind byte, session uint32, r *netReader) {
+	r = &netReader{buf: data}
+	kind = r.byte()
+	session = r.uint32()
+	return kind, session, r
+}
+
+func (r *netReader) next(n int) []byte {
+	if r.err != nil || len(r.buf) < n {
+		r.err = errShortMessage
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *netReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *netReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *netReader) int64() int64 {
+	return int64(binary.BigEndian.Uint64(r.next(8)))
+}
+
+func (r *netReader) bytes(n int) []byte {
+	return r.next(n)
+}
+
+// netTime returns the current time as sent in pings
+func netTime() int64 {
+	return time.Now().UnixNano()
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+const (
+	// How often the joining player asks to join, until the host answers
+	helloInterval = 250 * time.Millisecond
+
+	// How often the round trip time to the other player is measured
+	pingInterval = 500 * time.Millisecond
+
+	// The other player is considered disconnected when nothing is received for this long
+	disconnectTimeout = 3 * time.Second
+
+	// The match is over when nothing is received from the other player for this long
+	lostTimeout = 30 * time.Second
+
+	// The player who joins gives up when the host does not answer for this long
+	joinTimeout = 10 * time.Second
+)
+
+// errOtherConfig is the error of a player who joins a host with another configuration
+var errOtherConfig = errors.New("network: the host uses another configuration (-config and the flags), both players must use the same one")
+
+// errOtherVersion is the error of a player who joins a host with another version of the game
+var errOtherVersion = errors.New("network: the host uses another version of the game")
+
+// errNoHost is the error of a player who joins an address where nobody answers
+var errNoHost = errors.New("network: the host does not answer")
+
+// NetConfig holds the settings of an online match
+type NetConfig struct {
+	// The number of ticks between reading the local input and applying it (set by the host)
+	InputDelay int
+
+	// The maximum number of ticks simulated ahead of the input of the other player,
+	// 0 plays in lockstep (set by the host, see NetSession)
+	Rollback int
+
+	// Simulated network conditions for the packets sent by this side (see netPeer)
+	Latency, Jitter time.Duration
+	Loss            float64
+}
+
+// NetSession is a match played over the network.
+// Both players run the same simulation (same seed) and exchange only their input for every tick (frame).
+// The local input is applied inputDelay ticks after it was read, so that it has time to reach the other player.
+//
+// In lockstep, a tick is simulated only when the input of both players is known.
+// With rollback, the game does not wait for the input of the other player: it predicts it
+// (the keys are still held as in the last input received), and when the real input arrives and
+// differs from the prediction, the game is restored to the tick before and simulated again.
+// It can run at most rollback ticks ahead of the input of the other player.
+//
+// The host plays on the right (player 1), the player who joins on the left (player 2).
+type NetSession struct {
+	// The connection to the other player
+	peer *netPeer
+
+	// Whether this side hosts the match
+	host bool
+
+	// Creates the game of the match (in a window with sounds, see newOnlineGame)
+	newGame func(seed int64) *Game
+
+	// Identifies the match, so that the other player can reconnect from another address
+	session uint32
+
+	// The match (nil until the handshake is done)
+	game *Game
+
+	// HUD used to draw the messages before the match starts
+	hud *HUD
+
+	// The number of ticks between reading the local input and applying it
+	inputDelay int
+
+	// The maximum number of ticks simulated with a predicted remote input (0 in lockstep)
+	rollback int
+
+	// The input of the local and the remote player, one byte per tick (see encodeInput)
+	localInputs  []byte
+	remoteInputs []byte
+
+	// The remote input used to simulate every tick: the real one or a prediction (unusedInput if none was read)
+	remoteUsed []byte
+
+	// The number of ticks simulated with the real remote input
+	verified int
+
+	// The state of the game before each of the last ticks, to roll them back
+	snapshots []Snapshot
+
+	// The number of times the game was rolled back
+	rollbacks int
+
+	// The number of local inputs the other player has received (acknowledged)
+	remoteAck int
+
+	// The checksum of the game state every checkpointInterval ticks, to detect desyncs
+	checksums map[int]uint32
+
+	// The tick where the state of the two games stopped matching (0 if it never happened)
+	desyncTick int
+
+	// When a message was last received from the other player
+	lastReceived time.Time
+
+	// When a hello or a ping was last sent
+	lastHello, lastPing time.Time
+
+	// The last measured round trip time to the other player
+	ping time.Duration
+
+	// Whether the other player left the match
+	peerLeft bool
+
+	// Why the match cannot go on (e.g. the other player left), shown on the screen (empty while it goes on)
+	over string
+
+	// When this side started asking the host to join
+	joining time.Time
+
+	// Why the match cannot be played (e.g. the host refused to let this player join)
+	err error
+}
+
+// hostNetSession waits for another player to join on the given address (e.g. ":7777")
+func hostNetSession(address string, seed int64, config NetConfig) (*NetSession, error) {
+	peer, err := listenPeer(address)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newNetSession(peer, true, config, newOnlineGame)
+	if err != nil {
+		return nil, err
+	}
+	s.hostMatch(seed, config)
+	return s, nil
+}
+
+// joinNetSession joins the match hosted at the given address (e.g. "192.168.1.2:7777").
+// The input delay and the rollback of the match are the ones of the host.
+func joinNetSession(address string, config NetConfig) (*NetSession, error) {
+	peer, err := dialPeer(address)
+	if err != nil {
+		return nil, err
+	}
+	return newNetSession(peer, false, config, newOnlineGame)
+}
+
+// newOnlineGame creates the game of an online match, played in a window with sounds
+func newOnlineGame(seed int64) *Game {
+	return newGame(seed, twoPlayers)
+}
+
+// newNetSession creates the session of a player talking to the other one through peer,
+// where newGame creates the game of the match once the seed is known
+func newNetSession(peer *netPeer, host bool, config NetConfig, newGame func(seed int64) *Game) (*NetSession, error) {
+	hud, err := newHUD()
+	if err != nil {
+		return nil, err
+	}
+	peer.latency, peer.jitter, peer.loss = config.Latency, config.Jitter, config.Loss
+	return &NetSession{
+		peer:      peer,
+		host:      host,
+		newGame:   newGame,
+		hud:       hud,
+		checksums: map[int]uint32{},
+	}, nil
+}
+
+// hostMatch creates the match hosted by this side, which starts when the other player joins
+func (s *NetSession) hostMatch(seed int64, config NetConfig) {
+	s.session = uint32(newRand(seed).Uint64()) | 1 // never 0, which means no session
+	s.startGame(seed, config.InputDelay, config.Rollback)
+}
+
+// startGame creates the match, with the local player on its side and the remote player on the other
+func (s *NetSession) startGame(seed int64, inputDelay, rollback int) {
+	s.game = s.newGame(seed)
+	s.game.mode = twoPlayers
+	s.game.local = false // the other player would have to wait, while the game keeps running
+	s.inputDelay = inputDelay
+	s.rollback = rollback
+	if rollback > 0 {
+		s.snapshots = make([]Snapshot, rollback+1)
+	}
+
+	local := newInputController(s.inputAt(&s.localInputs))
+	remote := newInputController(s.predictRemoteInput)
+	if s.host {
+		s.game.player.controller, s.game.enemy.controller = local, remote
+	} else {
+		s.game.player.controller, s.game.enemy.controller = remote, local
+	}
+
+	// nobody can press anything during the first ticks, because of the input delay
+	for i := 0; i < inputDelay; i++ {
+		s.localInputs = append(s.localInputs, 0)
+		s.remoteInputs = append(s.remoteInputs, 0)
+	}
+}
+
+// inputAt returns an input source that reads the input of the current tick from inputs
+func (s *NetSession) inputAt(inputs *[]byte) InputSource {
+	return func(g *Game, _ *Paddle) PaddleInput {
+		return decodeInput((*inputs)[g.frame-1])
+	}
+}
+
+// predictRemoteInput is the input source of the remote player: it returns the input of the current tick,
+// or predicts it from the last input received if it has not arrived yet
+func (s *NetSession) predictRemoteInput(g *Game, _ *Paddle) PaddleInput {
+	tick := g.frame
+	var in byte
+	switch {
+	case tick <= len(s.remoteInputs):
+		in = s.remoteInputs[tick-1]
+	case len(s.remoteInputs) > 0:
+		in = s.remoteInputs[len(s.remoteInputs)-1]
+	}
+	s.remoteUsed[tick-1] = in
+	return decodeInput(in)
+}
+
+// Update exchanges the inputs with the other player and simulates the next tick when both inputs are known
+func (s *NetSession) Update() error {
+	s.receive()
+	if s.err != nil {
+		return s.err
+	}
+
+	now := time.Now()
+	if s.game == nil {
+		// keep asking the host to join, until it answers
+		if s.joining.IsZero() {
+			s.joining = now
+		}
+		if now.Sub(s.joining) >= joinTimeout {
+			return errNoHost
+		}
+		if now.Sub(s.lastHello) >= helloInterval {
+			s.peer.send(newMessage(msgHello, 0).uint32(netProtocolVersion).uint32(cfg.hash()).buf)
+			s.lastHello = now
+		}
+		return nil
+	}
+
+	switch {
+	case s.over != "":
+	case s.peerLeft:
+		s.over = s.otherName() + " LEFT"
+	case s.peer.remote != nil && now.Sub(s.lastReceived) >= lostTimeout:
+		s.over = "LOST " + s.otherName()
+	}
+	if s.over != "" {
+		// the match cannot go on, the pause key quits
+		if pausePressed() {
+			return ebiten.Termination
+		}
+		return nil
+	}
+	if s.peer.remote == nil {
+		return nil
+	}
+
+	if now.Sub(s.lastPing) >= pingInterval {
+		s.peer.send(newMessage(msgPing, s.session).int64(netTime()).buf)
+		s.lastPing = now
+	}
+
+	// read the local input for the tick inputDelay ticks ahead of the next one
+	nextTick := s.game.frame + 1
+	if len(s.localInputs) < nextTick+s.inputDelay {
+		s.localInputs = append(s.localInputs, encodeInput(readPlayer1(s.game, nil)))
+	}
+	s.sendInputs()
+
+	// correct the ticks simulated with a wrong prediction
+	if err := s.rollBack(); err != nil {
+		return err
+	}
+
+	// simulate the next tick only when the input of the other player is known,
+	// or when it can be predicted (at most rollback ticks ahead of it)
+	if len(s.remoteInputs)+s.rollback < nextTick || len(s.localInputs) < nextTick {
+		return nil
+	}
+	if s.rollback > 0 && s.aheadOfPeer() > 1 && nextTick%frameSkipInterval == 0 {
+		// let the other player catch up, instead of rolling back all the time
+		return nil
+	}
+	return s.step()
+}
+
+// step simulates the next tick
+func (s *NetSession) step() error {
+	if s.rollback > 0 {
+		s.snapshots[s.game.frame%len(s.snapshots)] = s.game.snapshot()
+	}
+	s.remoteUsed = append(s.remoteUsed[:s.game.frame], unusedInput)
+
+	if err := s.game.Update(); err != nil {
+		return err
+	}
+	if s.game.frame <= len(s.remoteInputs) && s.verified == s.game.frame-1 {
+		s.verified = s.game.frame
+	}
+
+	if s.game.frame%checkpointInterval == 0 {
+		s.checksums[s.game.frame] = s.game.checksum()
+		delete(s.checksums, s.game.frame-10*checkpointInterval)
+	}
+	return nil
+}
+
+// sendInputs sends the local inputs that the other player has not acknowledged yet,
+// along with the latest checksum of the game state
+func (s *NetSession) sendInputs() {
+	first := s.remoteAck
+	if len(s.localInputs)-first > maxInputsPerPacket {
+		first = len(s.localInputs) - maxInputsPerPacket
+	}
+	inputs := s.localInputs[first:]
+
+	// only the ticks simulated with the real input of both players can be compared
+	checkTick := s.verified - s.verified%checkpointInterval
+	msg := newMessage(msgInput, s.session).
+		uint32(uint32(len(s.remoteInputs))).
+		uint32(uint32(first)).
+		byte(byte(len(inputs))).
+		bytes(inputs).
+		uint32(uint32(checkTick)).
+		uint32(s.checksums[checkTick])
+	s.peer.send(msg.buf)
+}
+
+// receive handles all the messages received since the last update
+func (s *NetSession) receive() {
+	for {
+		select {
+		case p, ok := <-s.peer.packets:
+			if !ok {
+				return
+			}
+			s.handle(p)
+		default:
+			return
+		}
+	}
+}
+
+// handle handles a message from the other player
+func (s *NetSession) handle(p netPacket) {
+	kind, session, r := readMessage(p.data)
+	if r.err != nil {
+		return
+	}
+
+	switch kind {
+	case msgHello:
+		version := r.uint32()
+		if !s.host || r.err != nil {
+			return
+		}
+		if version != netProtocolVersion {
+			log.Printf("network: %v uses protocol version %d, expected %d", p.from, version, netProtocolVersion)
+			s.peer.sendTo(p.from, newMessage(msgReject, 0).byte(rejectVersion).uint32(netProtocolVersion).buf)
+			return
+		}
+		if hash := r.uint32(); r.err != nil || hash != cfg.hash() {
+			log.Printf("network: %v uses another configuration (-config and the flags), both players must use the same one", p.from)
+			s.peer.sendTo(p.from, newMessage(msgReject, 0).byte(rejectConfig).uint32(netProtocolVersion).buf)
+			return
+		}
+		if s.peer.remote != nil && !sameAddr(s.peer.remote, p.from) {
+			// the match already has two players
+			return
+		}
+		s.peer.remote = p.from
+		s.lastReceived = time.Now()
+		s.peer.send(newMessage(msgWelcome, s.session).int64(s.game.seed).byte(byte(s.inputDelay)).byte(byte(s.rollback)).uint32(cfg.hash()).buf)
+		return
+
+	case msgReject:
+		reason := r.byte()
+		version := r.uint32()
+		if s.host || s.game != nil || r.err != nil {
+			return
+		}
+		s.err = errOtherConfig
+		if reason == rejectVersion {
+			s.err = fmt.Errorf("%w (protocol version %d, expected %d)", errOtherVersion, version, netProtocolVersion)
+		}
+		return
+
+	case msgWelcome:
+		seed := r.int64()
+		inputDelay := int(r.byte())
+		rollback := int(r.byte())
+		hash := r.uint32()
+		if s.host || s.game != nil || r.err != nil {
+			return
+		}
+		if hash != cfg.hash() {
+			s.err = errOtherConfig
+			return
+		}
+		s.session = session
+		s.startGame(seed, inputDelay, rollback)
+		s.lastReceived = time.Now()
+		return
+	}
+
+	// all the other messages belong to the session
+	if s.game == nil || session != s.session {
+		return
+	}
+	s.lastReceived = time.Now()
+	if !sameAddr(s.peer.remote, p.from) {
+		// the other player reconnected from another address
+		s.peer.remote = p.from
+	}
+
+	switch kind {
+	case msgInput:
+		ack := int(r.uint32())
+		first := int(r.uint32())
+		count := int(r.byte())
+		inputs := r.bytes(count)
+		checkTick := int(r.uint32())
+		checksum := r.uint32()
+		if r.err != nil {
+			return
+		}
+		if ack > s.remoteAck {
+			s.remoteAck = ack
+		}
+		// append the inputs that were not received yet
+		for i, in := range inputs {
+			if first+i == len(s.remoteInputs) {
+				s.remoteInputs = append(s.remoteInputs, in)
+			}
+		}
+		if local, ok := s.checksums[checkTick]; ok && checkTick > 0 && checkTick <= s.verified && local != checksum && s.desyncTick == 0 {
+			s.desyncTick = checkTick
+			log.Printf("network: desync at tick %d", checkTick)
+		}
+
+	case msgPing:
+		sent := r.int64()
+		s.peer.send(newMessage(msgPong, s.session).int64(sent).buf)
+
+	case msgPong:
+		sent := r.int64()
+		if r.err == nil {
+			s.ping = time.Duration(netTime() - sent)
+		}
+
+	case msgBye:
+		s.peerLeft = true
+	}
+}
+
+// otherName returns how the other player is called on the screen
+func (s *NetSession) otherName() string {
+	if s.host {
+		return "PLAYER 2"
+	}
+	return "PLAYER 1"
+}
+
+// sameAddr returns true if both addresses are the same
+func sameAddr(a, b *net.UDPAddr) bool {
+	return a != nil && b != nil && a.IP.Equal(b.IP) && a.Port == b.Port
+}
+
+// connected returns true if the other player has sent something recently
+func (s *NetSession) connected() bool {
+	return time.Since(s.lastReceived) < disconnectTimeout
+}
+
+// Draw draws the match and the state of the connection
+func (s *NetSession) Draw(screen *ebiten.Image) {
+	if s.game == nil {
+		s.hud.drawMessage(screen, "JOINING...", halfGameScreenHeight)
+		return
+	}
+
+	s.game.Draw(screen)
+
+	other := s.otherName()
+	switch {
+	case s.over != "":
+		s.hud.drawMessage(screen, s.over, halfGameScreenHeight)
+		s.hud.drawCentered(screen, "PRESS "+cfg.Controls.Pause.String()+" TO QUIT", s.hud.ResultDisplayFont, halfGameScreenHeight+80)
+	case s.peer.remote == nil:
+		s.hud.drawMessage(screen, fmt.Sprintf("WAITING FOR %s ON %s", other, s.peer.conn.LocalAddr()), halfGameScreenHeight)
+	case !s.connected():
+		s.hud.drawMessage(screen, "WAITING FOR "+other+"...", halfGameScreenHeight)
+	}
+
+	status := fmt.Sprintf("PING %d ms", s.ping.Milliseconds())
+	if s.rollback > 0 {
+		status += fmt.Sprintf("  ROLLBACKS %d", s.rollbacks)
+	}
+	if s.desyncTick != 0 {
+		status += fmt.Sprintf("  DESYNC at tick %d", s.desyncTick)
+	}
+	ebitenutil.DebugPrintAt(screen, status, halfGameScreenWidth-40, screenHeight-20)
+}
+
+// Layout returns the size of the game screen
+func (s *NetSession) Layout(_, _ int) (int, int) {
+	return screenWidth, screenHeight
+}
+
+// Close tells the other player that this one is leaving, and closes the connection
+func (s *NetSession) Close() error {
+	if s.game != nil {
+		s.peer.send(newMessage(msgBye, s.session).buf)
+		// let the simulated network deliver it before closing
+		time.Sleep(s.peer.latency + s.peer.jitter)
+	}
+	return s.peer.close()
+}
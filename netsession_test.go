@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newPipeSessions creates a host and a player who joins it, connected in memory, with games without window or sounds
+func newPipeSessions(t *testing.T) (host, join *NetSession) {
+	t.Helper()
+	hostPeer, joinPeer := pipe()
+	host, err := newNetSession(hostPeer, true, NetConfig{}, newSimulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host.hostMatch(1, NetConfig{InputDelay: 2})
+	join, err = newNetSession(joinPeer, false, NetConfig{}, newSimulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, join
+}
+
+// TestJoinWithOtherConfig checks that a player with another configuration cannot join,
+// and that the same configuration can
+func TestJoinWithOtherConfig(t *testing.T) {
+	hostConfig := defaultConfig()
+	otherConfig := defaultConfig()
+	otherConfig.Ball.MaxSpeed++
+	controlsConfig := defaultConfig()
+	controlsConfig.Controls = ControlsConfig{}
+
+	tests := []struct {
+		name   string
+		config Config // of the player who joins
+		err    error
+	}{
+		{"same config", hostConfig, nil},
+		{"other controls", controlsConfig, nil},
+		{"other ball speed", otherConfig, errOtherConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, hostConfig)
+			host, join := newPipeSessions(t)
+
+			// both games run in this process, each one with its own config
+			var err error
+			for i := 0; i < 3 && err == nil && join.game == nil; i++ {
+				tt.config.apply()
+				err = join.Update()
+				hostConfig.apply()
+				if err == nil {
+					if err := host.Update(); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("joining returned %v, expected %v", err, tt.err)
+			}
+			if joined := join.game != nil; joined != (tt.err == nil) {
+				t.Errorf("joined %t, expected %t", joined, tt.err == nil)
+			}
+		})
+	}
+}
+
+// TestJoinWithOtherVersion checks that the host refuses a player with another protocol version, and tells it why
+func TestJoinWithOtherVersion(t *testing.T) {
+	useConfig(t, defaultConfig())
+	host, join := newPipeSessions(t)
+
+	// an older version of the game asks to join
+	join.peer.send(newMessage(msgHello, 0).uint32(netProtocolVersion - 1).uint32(cfg.hash()).buf)
+	if err := host.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if err := join.Update(); !errors.Is(err, errOtherVersion) {
+		t.Fatalf("joining returned %v, expected %v", err, errOtherVersion)
+	}
+	if join.game != nil {
+		t.Errorf("joined a host with another version")
+	}
+}
+
+// TestLostPeer checks that the match is over when nothing is received from the other player for too long
+func TestLostPeer(t *testing.T) {
+	useConfig(t, defaultConfig())
+	host, join := newPipeSessions(t)
+	for i := 0; i < 3 && join.game == nil; i++ {
+		if err := join.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if err := host.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if join.game == nil {
+		t.Fatal("could not join the host")
+	}
+
+	host.lastReceived = time.Now().Add(-lostTimeout)
+	if err := host.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if host.over == "" {
+		t.Errorf("the match goes on, %v after the last packet from the other player", lostTimeout)
+	}
+}
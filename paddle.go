@@ -5,6 +5,7 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 	"image/color"
+	"math"
 )
 
 type PaddleHolder interface {
@@ -13,20 +14,50 @@ type PaddleHolder interface {
 
 // Paddle is a struct that holds information about a paddle in the game
 type Paddle struct {
-	// The position of the paddle on the screen
-	position *rect.Rectangle
+	// The position of the paddle on the screen, in whole pixels for drawing and collisions
+	position rect.Rectangle
+
+	// The position of the top left corner of the paddle, with sub-pixel precision (see moveTo)
+	pos Vector2D
 
 	// The velocity (movement) of the paddle
-	velocity *Vector2D
+	velocity Vector2D
 
-	// The speed of the paddle
-	speed float64
+	// The vertical velocity its controller wants the paddle to move at (see Game.drive)
+	target float64
 }
 
 func (p *Paddle) GetPaddle() *Paddle {
 	return p
 }
 
+// newPaddle creates a paddle of the configured size, with its top left corner at (x, y)
+func newPaddle(x, y int) *Paddle {
+	p := &Paddle{position: *rect.Rect(x, y, cfg.Paddle.Width, cfg.Paddle.Height)}
+	p.moveTo(float64(x), float64(y))
+	return p
+}
+
+// moveTo moves the top left corner of the paddle to (x, y), and its rectangle to the nearest pixel
+func (p *Paddle) moveTo(x, y float64) {
+	p.pos = Vector2D{X: x, Y: y}
+	p.position.X, p.position.Y = int(math.Round(x)), int(math.Round(y))
+}
+
+// resize changes the height of the paddle, keeping its center where it is (but within the screen)
+func (p *Paddle) resize(height int) {
+	y := p.pos.Y + float64(p.position.Height-height)/2
+	p.position.Height = height
+	p.moveTo(p.pos.X, math.Max(0, math.Min(float64(screenHeight-height), y)))
+}
+
+// move moves the paddle by its velocity, without leaving the screen
+func (p *Paddle) move() {
+	y := p.pos.Y + p.velocity.Y
+	y = math.Max(0, math.Min(float64(screenHeight-p.position.Height), y))
+	p.moveTo(p.pos.X+p.velocity.X, y)
+}
+
 func (p *Paddle) Draw(screen *ebiten.Image) {
 	// draw player
 	op := &ebiten.DrawImageOptions{}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// pausePressed returns true if a pause key (Esc or P by default) or button (Start) was just pressed
+func pausePressed() bool {
+	return cfg.Controls.Pause.justPressed()
+}
+
+// handlePause pauses the match when a pause key is pressed, the window loses the focus or the gamepad of a player
+// is unplugged, and resumes it when a pause key is pressed again.
+// It returns true if the match was paused or resumed.
+func (g *Game) handlePause() bool {
+	if !g.local {
+		return false
+	}
+	switch g.state {
+	case playing, firstService:
+		if pausePressed() || !ebiten.IsFocused() || gamepads.unplugged() {
+			g.pause()
+			return true
+		}
+	case paused:
+		// the pause key can be waited for, to be bound to another key
+		if g.menu.listen == nil && pausePressed() {
+			g.resume()
+			return true
+		}
+	}
+	return false
+}
+
+// pause stops the match and shows the pause menu
+func (g *Game) pause() {
+	g.resumeState = g.state
+	g.state = paused
+	g.menu = g.pauseMenu()
+}
+
+// resume goes back to the match where it was paused
+func (g *Game) resume() {
+	g.state = g.resumeState
+	g.menu = nil
+}
+
+// pauseMenu creates the menu shown while the match is paused
+func (g *Game) pauseMenu() *Menu {
+	m := newMenu("PAUSED",
+		MenuItem{label: "RESUME", action: g.resume},
+		MenuItem{label: "RESTART MATCH", action: g.restart},
+		MenuItem{label: "SETTINGS", action: func() {
+			pauseMenu := g.menu
+			g.menu = newSettingsMenu(
+				func() AIProfile { return g.difficulty },
+				g.setDifficulty,
+				func(menu *Menu) { g.menu = menu },
+				func() { g.menu = pauseMenu },
+			)
+		}},
+	)
+	if g.leave != nil {
+		m.items = append(m.items, MenuItem{label: "QUIT", action: g.leave})
+	}
+	return m
+}
+
+// restart starts a new match with the same players and settings.
+// The seed of the new match is picked by the random number generator, so that a restarted match can be reproduced too.
+func (g *Game) restart() {
+	g.seed = int64(g.rng.Uint64())
+	*g.rng = *newRand(g.seed)
+	log.Printf("seed: %d", g.seed)
+
+	g.score = Score{}
+	g.turn = user
+	g.frame = 0
+	g.volleyCount = 0
+	g.longestVolley = 0
+
+	// the sounds are not part of the match
+	sounds := g.ball.sounds
+	*g.ball = *newBall()
+	g.ball.sounds = sounds
+	*g.player.paddle = *newPlayer().paddle
+	*g.enemy.paddle = *newEnemy().paddle
+	resetController(g.player.controller)
+	resetController(g.enemy.controller)
+	g.clearPowerUps()
+
+	g.menu = nil
+	g.state = firstService
+}
+
+// resetController forgets what the controller remembers from the previous match
+func resetController(c Controller) {
+	if c, ok := c.(*AIController); ok {
+		*c = *newAIController(c.profile)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// readMouse is an input source that moves the paddle towards the mouse cursor, at most as fast as the keys
+func readMouse(g *Game, p *Paddle) PaddleInput {
+	_, y := ebiten.CursorPosition()
+	return PaddleInput{Analog: towards(g, p, float64(y))}
+}
+
+// TouchDrag is a finger dragging a paddle: the paddle follows the moves of the finger up and down,
+// from where it was when the finger touched the screen
+type TouchDrag struct {
+	// The finger, if dragging is true
+	id       ebiten.TouchID
+	dragging bool
+
+	// Where the finger touched the screen, and where the center of the paddle was then
+	startY  int
+	paddleY float64
+}
+
+// readTouch returns an input source that moves the paddle with a finger dragged anywhere on its half of the screen.
+// Every paddle controlled by touch needs its own source, to follow its own finger.
+func readTouch() InputSource {
+	var drag TouchDrag
+	return func(g *Game, p *Paddle) PaddleInput {
+		if drag.dragging && !containsTouch(ebiten.AppendTouchIDs(nil), drag.id) {
+			drag.dragging = false
+		}
+		if !drag.dragging {
+			right := p.position.CenterX() >= halfGameScreenWidth
+			for _, id := range inpututil.AppendJustPressedTouchIDs(nil) {
+				if x, y := ebiten.TouchPosition(id); (x >= halfGameScreenWidth) == right {
+					drag = TouchDrag{id: id, dragging: true, startY: y, paddleY: paddleCenterY(p)}
+					break
+				}
+			}
+		}
+		if !drag.dragging {
+			return PaddleInput{}
+		}
+		_, y := ebiten.TouchPosition(drag.id)
+		return PaddleInput{Analog: towards(g, p, drag.paddleY+float64(y-drag.startY))}
+	}
+}
+
+// containsTouch returns true if id is one of the ids
+func containsTouch(ids []ebiten.TouchID, id ebiten.TouchID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// paddleCenterY returns the Y position of the center of the paddle p, with sub-pixel precision
+func paddleCenterY(p *Paddle) float64 {
+	return p.pos.Y + float64(p.position.Height)/2
+}
+
+// towards returns the analog level (see PaddleInput.Analog) that brings the center of the paddle p closer to y,
+// as fast as possible without going past it
+func towards(g *Game, p *Paddle, y float64) int {
+	distance := y - paddleCenterY(p)
+	speed := math.Abs(distance)
+	if g.rules.Momentum {
+		// no faster than the paddle can stop from, before it gets there
+		speed = math.Sqrt(2 * g.rules.MomentumLimits.Friction * speed)
+	}
+
+	// truncated, so that the paddle stops short of y instead of going back and forth around it
+	level := int(math.Copysign(speed, distance) / g.paddleSpeed() * analogSteps)
+	if level < -analogSteps {
+		return -analogSteps
+	}
+	if level > analogSteps {
+		return analogSteps
+	}
+	return level
+}
+
+// pointerJustPressed returns the position of the mouse or the finger that was just pressed on the screen, if any
+func pointerJustPressed() (x, y int, ok bool) {
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		x, y := ebiten.CursorPosition()
+		return x, y, true
+	}
+	if ids := inpututil.AppendJustPressedTouchIDs(nil); len(ids) > 0 {
+		x, y := ebiten.TouchPosition(ids[0])
+		return x, y, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/drpaneas/rect"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font/basicfont"
+)
+
+// How strong the power-ups are
+const (
+	// The factors applied to the height of a paddle enlarged or shrunk
+	enlargeFactor = 1.5
+	shrinkFactor  = 0.6
+
+	// The factors applied to the speed of the balls sped up or slowed down
+	speedUpFactor  = 1.4
+	slowDownFactor = 0.7
+
+	// The number of balls added by multi-ball, and the angle between them and the ball that collected it, in degrees
+	multiBalls     = 2
+	multiBallAngle = 15
+
+	// The most extra balls on the court at once
+	maxExtraBalls = 4
+
+	// The frames a sticky paddle holds the ball before it lets it go
+	stickyHold = 40
+)
+
+// PowerUpConfig is how the power-ups appear on the court, when the rules of the match have power-ups (see MatchRules.PowerUps).
+// A power-up is collected by the side that last hit the ball touching it, and most of them act for a while (see Effect).
+type PowerUpConfig struct {
+	// The frames between two power-ups appearing on the court (60 frames per second)
+	Interval int `json:"interval"`
+
+	// The frames a power-up stays on the court, if it is not collected
+	Lifetime int `json:"lifetime"`
+
+	// The frames the effect of a power-up lasts
+	Duration int `json:"duration"`
+
+	// The most power-ups on the court at once
+	Max int `json:"max"`
+
+	// The width and height of the power-ups on the court, in pixels
+	Size int `json:"size"`
+}
+
+// defaultPowerUpConfig returns the power-ups the game was designed with
+func defaultPowerUpConfig() PowerUpConfig {
+	return PowerUpConfig{Interval: 360, Lifetime: 600, Duration: 600, Max: 2, Size: 30}
+}
+
+// validate returns an error if the power-ups make no sense
+func (c PowerUpConfig) validate() error {
+	switch {
+	case c.Interval < 1:
+		return fmt.Errorf("powerUps.interval must be at least 1 frame, got %d", c.Interval)
+	case c.Lifetime < 1:
+		return fmt.Errorf("powerUps.lifetime must be at least 1 frame, got %d", c.Lifetime)
+	case c.Duration < 1:
+		return fmt.Errorf("powerUps.duration must be at least 1 frame, got %d", c.Duration)
+	case c.Max < 1:
+		return fmt.Errorf("powerUps.max must be at least 1, got %d", c.Max)
+	case c.Size < 10 || c.Size > 100:
+		return fmt.Errorf("powerUps.size must be between 10 and 100 pixels, got %d", c.Size)
+	}
+	return nil
+}
+
+// PowerUpKind is what a power-up does
+type PowerUpKind int
+
+const (
+	// enlargePowerUp makes the paddle of the side collecting it taller
+	enlargePowerUp PowerUpKind = iota
+
+	// shrinkPowerUp makes the paddle of the other side shorter
+	shrinkPowerUp
+
+	// speedUpPowerUp and slowDownPowerUp change the speed of all the balls
+	speedUpPowerUp
+	slowDownPowerUp
+
+	// multiBallPowerUp adds balls going the same way as the ball collecting it, right away
+	multiBallPowerUp
+
+	// stickyPowerUp makes the paddle of the side collecting it hold the balls it hits for a moment
+	stickyPowerUp
+
+	// shieldPowerUp puts a wall behind the paddle of the side collecting it, which sends back the next ball going in its goal
+	shieldPowerUp
+)
+
+// powerUpKinds are how the kinds of power-ups are shown, in the order of PowerUpKind
+var powerUpKinds = []struct {
+	symbol string
+	color  color.RGBA
+}{
+	{"+", color.RGBA{R: 0x40, G: 0xd0, B: 0x40, A: 0xff}},
+	{"-", color.RGBA{R: 0xe0, G: 0x40, B: 0x40, A: 0xff}},
+	{">", color.RGBA{R: 0xff, G: 0x90, B: 0x20, A: 0xff}},
+	{"<", color.RGBA{R: 0x40, G: 0x90, B: 0xff, A: 0xff}},
+	{"M", color.RGBA{R: 0xff, G: 0xe0, B: 0x40, A: 0xff}},
+	{"S", color.RGBA{R: 0xc0, G: 0x60, B: 0xff, A: 0xff}},
+	{"#", color.RGBA{R: 0x40, G: 0xe0, B: 0xe0, A: 0xff}},
+}
+
+// shared returns true if the power-up acts on both sides, whoever collected it
+func (k PowerUpKind) shared() bool {
+	return k == speedUpPowerUp || k == slowDownPowerUp
+}
+
+// Side is a side of the court
+type Side int
+
+const (
+	noSide Side = iota
+
+	// playerSide is the right side, enemySide the left side
+	playerSide
+	enemySide
+)
+
+// other returns the other side of the court
+func (s Side) other() Side {
+	switch s {
+	case playerSide:
+		return enemySide
+	case enemySide:
+		return playerSide
+	}
+	return noSide
+}
+
+// paddleOf returns the paddle of the side
+func (g *Game) paddleOf(s Side) *Paddle {
+	if s == playerSide {
+		return g.player.paddle
+	}
+	return g.enemy.paddle
+}
+
+// PowerUp is a power-up waiting on the court to be collected
+type PowerUp struct {
+	kind     PowerUpKind
+	position rect.Rectangle
+
+	// The frames left before it disappears
+	left int
+}
+
+// Update counts down the frames the power-up stays on the court (Game.updatePowerUps takes it off)
+func (p *PowerUp) Update() {
+	p.left--
+}
+
+// Draw draws the power-up, blinking during the last 2 seconds it stays on the court
+func (p *PowerUp) Draw(screen *ebiten.Image) {
+	if p.left < 120 && p.left/8%2 == 0 {
+		return
+	}
+	drawPowerUpIcon(screen, p.kind, p.position.X, p.position.Y, p.position.Width)
+}
+
+// drawPowerUpIcon draws the symbol of the kind of power-up in a square of the size, with its top left corner at (x, y)
+func drawPowerUpIcon(screen *ebiten.Image, kind PowerUpKind, x, y, size int) {
+	k := powerUpKinds[kind]
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(size), float32(size), k.color)
+	bounds := text.BoundString(basicfont.Face7x13, k.symbol)
+	text.Draw(screen, k.symbol, basicfont.Face7x13, x+(size-bounds.Dx())/2-bounds.Min.X, y+(size-bounds.Dy())/2-bounds.Min.Y, color.Black)
+}
+
+// Effect is a power-up acting on a side of the court for a while
+type Effect struct {
+	kind PowerUpKind
+	side Side
+
+	// The frames left before it wears off, out of the frames it lasts
+	left, duration int
+}
+
+// balls returns the balls on the court: the ball, then the extra balls
+func (g *Game) balls() []*Ball {
+	return append([]*Ball{g.ball}, g.extraBalls...)
+}
+
+// updateObjects puts the power-ups, the ball, the paddles and the extra balls in the objects of the game,
+// in the order they are drawn
+func (g *Game) updateObjects() {
+	g.objects = g.objects[:0]
+	for _, p := range g.powerUps {
+		g.objects = append(g.objects, p)
+	}
+	g.objects = append(g.objects, g.ball, g.player, g.enemy)
+	for _, b := range g.extraBalls {
+		g.objects = append(g.objects, b)
+	}
+}
+
+// updatePowerUps gives the power-ups touched by the balls to the side that hit them last, takes off the court the ones
+// that were not collected in time, wears the effects off and makes a new power-up appear every interval
+func (g *Game) updatePowerUps() {
+	powerUps := g.powerUps[:0]
+	for _, p := range g.powerUps {
+		if ball := g.collector(p); ball != nil {
+			g.collect(p.kind, ball)
+		} else if p.left > 0 {
+			powerUps = append(powerUps, p)
+		}
+	}
+	g.powerUps = powerUps
+
+	effects := g.effects[:0]
+	for _, e := range g.effects {
+		e.left--
+		if e.left > 0 {
+			effects = append(effects, e)
+		} else {
+			g.cue("expire")
+		}
+	}
+	g.effects = effects
+
+	g.powerUpClock++
+	if g.powerUpClock >= cfg.PowerUps.Interval {
+		g.powerUpClock = 0
+		if len(g.powerUps) < cfg.PowerUps.Max {
+			g.spawnPowerUp()
+		}
+	}
+
+	g.resizePaddles()
+	g.updateObjects()
+}
+
+// spawnPowerUp puts a power-up of a random kind in the middle of the court, away from the paddles
+func (g *Game) spawnPowerUp() {
+	size := cfg.PowerUps.Size
+	x := g.rng.randInt(halfGameScreenWidth-screenWidth/4, halfGameScreenWidth+screenWidth/4-size)
+	y := g.rng.randInt(0, screenHeight-size)
+	kind := PowerUpKind(g.rng.Intn(len(powerUpKinds)))
+	g.powerUps = append(g.powerUps, &PowerUp{kind: kind, position: *rect.Rect(x, y, size, size), left: cfg.PowerUps.Lifetime})
+	g.cue("spawn")
+}
+
+// collector returns the ball collecting the power-up p: the first ball touching it that was hit by a paddle, if any
+func (g *Game) collector(p *PowerUp) *Ball {
+	for _, ball := range g.balls() {
+		if ball.hitter != noSide && ball.position.CollidesWith(&p.position) {
+			return ball
+		}
+	}
+	return nil
+}
+
+// collect gives the power-up of the kind to the side that last hit the ball collecting it
+func (g *Game) collect(kind PowerUpKind, ball *Ball) {
+	g.cue("powerup")
+	switch kind {
+	case shrinkPowerUp:
+		g.addEffect(kind, ball.hitter.other())
+	case speedUpPowerUp, slowDownPowerUp:
+		if g.addEffect(kind, ball.hitter) {
+			factor := speedUpFactor
+			if kind == slowDownPowerUp {
+				factor = slowDownFactor
+			}
+			for _, b := range g.balls() {
+				b.velocity.X *= factor
+				b.velocity.Y *= factor
+			}
+		}
+	case multiBallPowerUp:
+		g.addBalls(ball)
+	default:
+		g.addEffect(kind, ball.hitter)
+	}
+}
+
+// addEffect makes the power-up of the kind act on the side for the configured duration.
+// It returns false if it was already acting, it then lasts the whole duration again.
+func (g *Game) addEffect(kind PowerUpKind, side Side) bool {
+	if i := g.effectIndex(kind, side); i >= 0 {
+		g.effects[i].side = side
+		g.effects[i].left = g.effects[i].duration
+		return false
+	}
+	g.effects = append(g.effects, Effect{kind: kind, side: side, left: cfg.PowerUps.Duration, duration: cfg.PowerUps.Duration})
+	return true
+}
+
+// effectIndex returns the index of the effect of the kind acting on the side (or on both sides), -1 if there is none
+func (g *Game) effectIndex(kind PowerUpKind, side Side) int {
+	for i, e := range g.effects {
+		if e.kind == kind && (e.side == side || kind.shared()) {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasEffect returns true if the power-up of the kind acts on the side
+func (g *Game) hasEffect(kind PowerUpKind, side Side) bool {
+	return g.effectIndex(kind, side) >= 0
+}
+
+// setSounds gives the sounds to the ball and to the extra balls (nil mutes them)
+func (g *Game) setSounds(sounds map[string]*Sound) {
+	g.ball.sounds = sounds
+	for _, b := range g.extraBalls {
+		b.sounds = sounds
+	}
+}
+
+// addBalls adds the balls of multi-ball where the ball is, going the same way a little up and a little down
+func (g *Game) addBalls(ball *Ball) {
+	for i := 0; i < multiBalls && len(g.extraBalls) < maxExtraBalls; i++ {
+		b := newBall()
+		b.sounds = g.ball.sounds
+		b.moveTo(ball.pos.X, ball.pos.Y)
+		b.velocity, b.hitter = ball.velocity, ball.hitter
+		b.turn(multiBallAngle * float64(2*i-1))
+		g.extraBalls = append(g.extraBalls, b)
+	}
+}
+
+// removeExtraBalls takes the extra balls off the court
+func (g *Game) removeExtraBalls() {
+	if len(g.extraBalls) > 0 {
+		g.extraBalls = nil
+		g.updateObjects()
+	}
+}
+
+// clearPowerUps takes the power-ups, their effects and the extra balls off the court
+func (g *Game) clearPowerUps() {
+	g.powerUps, g.effects, g.extraBalls, g.powerUpClock = nil, nil, nil, 0
+	g.updateObjects()
+}
+
+// ballSpeed returns the speed of the balls after the first volleys, changed by the power-ups
+func (g *Game) ballSpeed() float64 {
+	return maxBallSpeed * g.ballSpeedFactor()
+}
+
+// ballSpeedFactor returns the factor applied to the speed of the balls by the power-ups
+func (g *Game) ballSpeedFactor() float64 {
+	factor := 1.0
+	for _, e := range g.effects {
+		switch e.kind {
+		case speedUpPowerUp:
+			factor *= speedUpFactor
+		case slowDownPowerUp:
+			factor *= slowDownFactor
+		}
+	}
+	return factor
+}
+
+// paddleHeight returns the height of the paddle of the side, with the power-ups acting on it
+func (g *Game) paddleHeight(side Side) int {
+	height := float64(cfg.Paddle.Height)
+	if g.hasEffect(enlargePowerUp, side) {
+		height *= enlargeFactor
+	}
+	if g.hasEffect(shrinkPowerUp, side) {
+		height *= shrinkFactor
+	}
+	// the paddle keeps a pixel for every bounce zone, and room to move on the screen
+	return int(math.Max(float64(cfg.Bounce.Zones), math.Min(float64(screenHeight-1), math.Round(height))))
+}
+
+// resizePaddles makes the paddles as tall as the power-ups acting on them want
+func (g *Game) resizePaddles() {
+	for _, side := range []Side{playerSide, enemySide} {
+		if p, height := g.paddleOf(side), g.paddleHeight(side); p.position.Height != height {
+			p.resize(height)
+		}
+	}
+}
+
+// powerUpHit applies the power-ups to the ball that has just bounced off the paddle p:
+// it goes as fast as the balls go now, and a sticky paddle catches it
+func (g *Game) powerUpHit(ball *Ball, p *Paddle) {
+	factor := g.ballSpeedFactor()
+	ball.velocity.X *= factor
+	ball.velocity.Y *= factor
+	if g.hasEffect(stickyPowerUp, ball.hitter) {
+		ball.held = stickyHold
+		ball.heldOffset = ball.pos.Y - p.pos.Y
+	}
+}
+
+// holdBall moves the ball held by a sticky paddle along with the paddle, and lets it go when the time is up
+func (g *Game) holdBall(ball *Ball) error {
+	p := g.paddleOf(ball.hitter)
+	y := math.Max(0, math.Min(float64(screenHeight-ball.position.Height), p.pos.Y+ball.heldOffset))
+	ball.moveTo(ball.pos.X, y)
+	ball.held--
+	if ball.held == 0 {
+		return ball.playSound("paddle")
+	}
+	return nil
+}
+
+// shieldBlocks sends back the ball going in a goal if the side of the goal has a shield, which is then used up.
+// It returns false if the side has no shield, the ball then scores.
+func (g *Game) shieldBlocks(ball *Ball) bool {
+	if !g.rules.PowerUps {
+		return false
+	}
+	side, x, velocityX := enemySide, 1.0, math.Abs(ball.velocity.X)
+	if ball.position.Right() >= screenWidth {
+		side, x, velocityX = playerSide, float64(screenWidth-ball.position.Width-1), -velocityX
+	}
+	i := g.effectIndex(shieldPowerUp, side)
+	if i < 0 {
+		return false
+	}
+	g.effects = append(g.effects[:i], g.effects[i+1:]...)
+	ball.moveTo(x, ball.pos.Y)
+	ball.velocity.X = velocityX
+	g.cue("wall")
+	return true
+}
+
+// handleExtraBallScores gives the point of the extra balls that went in a goal, and takes them off the court.
+// The round goes on with the other balls, unless the point decides the set or the match.
+func (g *Game) handleExtraBallScores() error {
+	if g.state == gameOver {
+		// the main ball has just decided the match
+		return nil
+	}
+	for i := 0; i < len(g.extraBalls); i++ {
+		ball := g.extraBalls[i]
+		s := &g.score
+		switch {
+		case ball.position.Left() <= 0 && !g.shieldBlocks(ball):
+			s.player++
+		case ball.position.Right() >= screenWidth && !g.shieldBlocks(ball):
+			s.enemy++
+		default:
+			continue
+		}
+		if err := ball.playSound("score"); err != nil {
+			return err
+		}
+		g.extraBalls = append(g.extraBalls[:i], g.extraBalls[i+1:]...)
+		i--
+		g.updateObjects()
+
+		if g.rules.wonSet(s.player, s.enemy) || g.rules.wonSet(s.enemy, s.player) || g.timeUp() {
+			g.checkWinCondition()
+			return nil
+		}
+	}
+	return nil
+}
+
+// cue plays a sound effect of the power-ups (the game goes on without it if it cannot be played)
+func (g *Game) cue(name string) {
+	_ = g.ball.playSound(name)
+}
+
+// drawEffects draws the shields on the court, and the icons of the effects acting on each side below its score,
+// with a bar showing how long they still last
+func (g *Game) drawEffects(screen *ebiten.Image) {
+	const iconSize, top = 24, 180
+	for _, side := range []Side{enemySide, playerSide} {
+		x, shieldX := halfGameScreenWidth-360, 0
+		if side == playerSide {
+			x, shieldX = halfGameScreenWidth+360-75, screenWidth-6
+		}
+		for _, e := range g.effects {
+			if e.side != side {
+				continue
+			}
+			drawPowerUpIcon(screen, e.kind, x, top, iconSize)
+			left := float32(iconSize) * float32(e.left) / float32(e.duration)
+			vector.DrawFilledRect(screen, float32(x), top+iconSize+4, left, 4, color.White)
+			x += iconSize + 8
+
+			if e.kind == shieldPowerUp {
+				vector.DrawFilledRect(screen, float32(shieldX), 0, 6, float32(screenHeight), powerUpKinds[shieldPowerUp].color)
+			}
+		}
+	}
+}
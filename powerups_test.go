@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+// useConfig makes c the configuration of the game until the end of the test
+func useConfig(t *testing.T, c Config) {
+	t.Helper()
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	c.apply()
+	t.Cleanup(defaultConfig().apply)
+}
+
+// TestEnlargedPaddleHeight checks that an enlarged paddle always leaves room to move on the screen
+func TestEnlargedPaddleHeight(t *testing.T) {
+	c := defaultConfig()
+	for _, height := range []int{110, 480, 500, c.Screen.Height - 1} {
+		c.Paddle.Height = height
+		useConfig(t, c)
+
+		g := newSimulation(1)
+		g.addEffect(enlargePowerUp, playerSide)
+		if got := g.paddleHeight(playerSide); got >= screenHeight {
+			t.Errorf("paddle of %d pixels enlarged to %d pixels, the screen is %d pixels high", height, got, screenHeight)
+		}
+	}
+}
+
+// TestTallPaddlesWithPowerUps plays arcade matches with paddles enlarged beyond the screen,
+// which used to leave the AI nowhere to patrol
+func TestTallPaddlesWithPowerUps(t *testing.T) {
+	c := defaultConfig()
+	c.Paddle.Height = 480
+	useConfig(t, c)
+
+	rules := defaultMatchRules
+	rules.PowerUps = true
+	for _, seed := range []int64{4, 7, 8} {
+		_, err := RunHeadless(HeadlessConfig{
+			Seed:      seed,
+			MaxFrames: 20000,
+			Player:    newAIController(defaultAIProfile),
+			Enemy:     newAIController(defaultAIProfile),
+			Rules:     rules,
+		})
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+}
+
+// TestExtraBallAfterMatchPoint checks that an extra ball going in a goal on the frame
+// the main ball wins the match does not count
+func TestExtraBallAfterMatchPoint(t *testing.T) {
+	useConfig(t, defaultConfig())
+
+	g := newSimulation(1)
+	g.rules.PowerUps = true
+	g.state = playing
+	g.score.player = g.rules.PointsToWin - 1
+	g.ball.moveTo(0, 100)
+	g.ball.velocity = Vector2D{X: -10}
+	g.addBalls(g.ball)
+	g.extraBalls[0].moveTo(0, 300)
+
+	if err := g.handleScore(); err != nil {
+		t.Fatal(err)
+	}
+	if g.state != gameOver {
+		t.Fatalf("the match point did not end the match")
+	}
+	if sets := g.score.Sets(); g.score.playerSets != 1 || g.score.enemySets != 0 {
+		t.Errorf("sets %s after the match point, expected 0 - 1", sets)
+	}
+	if g.score.player != g.rules.PointsToWin || g.score.enemy != 0 {
+		t.Errorf("score %s after the match point, expected 0 - %d", g.score, g.rules.PointsToWin)
+	}
+}
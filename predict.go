@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// maxPredictedBounces limits how many wall bounces are followed when predicting the ball trajectory
+const maxPredictedBounces = 100
+
+// ballPath predicts the trajectory of the center of a ball at position pos, moving with velocity vel,
+// until it reaches the vertical line at x. It returns the points where the ball bounces off
+// the top and bottom walls (taking into account its radius), followed by the point where it reaches x.
+// If walls is false, the ball goes straight through the walls (like a naive prediction would do).
+// It returns nil if the ball is not moving towards x.
+func ballPath(pos, vel Vector2D, radius, x float64, walls bool) []Vector2D {
+	if vel.X == 0 || (x-pos.X)*vel.X < 0 {
+		return nil
+	}
+
+	var path []Vector2D
+	for i := 0; i <= maxPredictedBounces; i++ {
+		// time (in frames) to reach x
+		timeToX := (x - pos.X) / vel.X
+		if !walls || vel.Y == 0 {
+			return append(path, Vector2D{X: x, Y: pos.Y + vel.Y*timeToX})
+		}
+
+		// time (in frames) to reach the wall the ball is moving towards
+		wallY := radius
+		if vel.Y > 0 {
+			wallY = float64(screenHeight) - radius
+		}
+		timeToWall := (wallY - pos.Y) / vel.Y
+		if timeToWall >= timeToX {
+			return append(path, Vector2D{X: x, Y: pos.Y + vel.Y*timeToX})
+		}
+
+		// bounce off the wall
+		pos = Vector2D{X: pos.X + vel.X*timeToWall, Y: wallY}
+		vel.Y = -vel.Y
+		path = append(path, pos)
+	}
+
+	return path
+}
+
+// ballCenter returns the position of the center of the ball, with sub-pixel precision
+func ballCenter(ball *Ball) Vector2D {
+	return Vector2D{
+		X: ball.pos.X + float64(ball.position.Width)/2,
+		Y: ball.pos.Y + float64(ball.position.Height)/2,
+	}
+}
+
+// interceptX returns the X position of the center of the ball when it touches the face of the paddle p
+func interceptX(ball *Ball, p *Paddle) float64 {
+	radius := float64(ball.position.Width) / 2
+	if p.position.CenterX() < halfGameScreenWidth {
+		return p.pos.X + float64(p.position.Width) + radius
+	}
+	return p.pos.X - radius
+}
+
+// drawPrediction draws the predicted trajectory of the ball (with wall bounces)
+// up to the paddle it is moving towards, and marks the predicted intercept
+func (g *Game) drawPrediction(screen *ebiten.Image) {
+	target := g.player.paddle
+	if g.ball.velocity.X < 0 {
+		target = g.enemy.paddle
+	}
+
+	from := ballCenter(g.ball)
+	path := ballPath(from, g.ball.velocity, float64(g.ball.position.Height)/2, interceptX(g.ball, target), true)
+	if len(path) == 0 {
+		return
+	}
+
+	lineColor := color.RGBA{R: 0xff, G: 0x40, B: 0x40, A: 0xff}
+	for _, to := range path {
+		vector.StrokeLine(screen, float32(from.X), float32(from.Y), float32(to.X), float32(to.Y), 2, lineColor)
+		from = to
+	}
+	intercept := path[len(path)-1]
+	vector.DrawFilledRect(screen, float32(intercept.X)-6, float32(intercept.Y)-6, 12, 12, lineColor)
+}
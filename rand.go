@@ -0,0 +1,45 @@
+package main
+
+import "math"
+
+// Rand is a small seedable pseudo-random number generator (SplitMix64).
+// Every game owns its own Rand, so the same seed always plays out the same way,
+// and its whole state is a single number that can be saved and restored.
+type Rand struct {
+	state uint64
+}
+
+// newRand creates a random number generator seeded with seed
+func newRand(seed int64) *Rand {
+	return &Rand{state: uint64(seed)}
+}
+
+// Uint64 returns the next pseudo-random number
+func (r *Rand) Uint64() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Intn returns a pseudo-random number in [0, n). It panics if n <= 0.
+func (r *Rand) Intn(n int) int {
+	if n <= 0 {
+		panic("invalid argument to Intn")
+	}
+	return int(r.Uint64() % uint64(n))
+}
+
+// Float64 returns a pseudo-random number in [0.0, 1.0)
+func (r *Rand) Float64() float64 {
+	return float64(r.Uint64()>>11) / (1 << 53)
+}
+
+// NormFloat64 returns a normally distributed pseudo-random number
+// (mean 0, standard deviation 1), using the Box-Muller transform
+func (r *Rand) NormFloat64() float64 {
+	u1 := 1 - r.Float64() // in (0, 1], so that the logarithm is finite
+	u2 := r.Float64()
+	return math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+}
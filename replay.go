@@ -0,0 +1,358 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+)
+
+// replayVersion is the version of the replay file format.
+// Version 1 only recorded the player's input, version 2 records the input of both paddles.
+// Version 3 bounces the ball off both paddles the same way, version 4 sweeps the ball against the paddles
+// instead of checking if they overlap, and version 5 moves the ball and the paddles by fractions of pixels:
+// the older replays cannot be played back anymore, their physics are different.
+// Version 6 records the analog controls.
+// Version 7 bounces the ball off the paddles at exact angles, instead of rounding its velocity to whole pixels.
+// Only the replays of this version are played back, the physics change with each version.
+const replayVersion = 7
+
+// The position of the input of each paddle within the byte recorded for a frame
+const (
+	playerInputShift = 0
+	enemyInputShift  = 4
+)
+
+// checkpointInterval is the number of frames between two checkpoints of a replay
+const checkpointInterval = 60
+
+// Replay is a recorded match: the seed and the input of the paddles for every frame,
+// plus checkpoints of the game state that are used to detect desyncs during playback.
+// Paddles controlled by the AI are replayed by the AI, because its moves depend only on the seed,
+// and all the other paddles are replayed from their recorded input.
+type Replay struct {
+	// The version of the file format
+	Version int
+
+	// The seed of the random number generator of the recorded game
+	Seed int64
+
+	// The game mode of the recorded game
+	Mode GameMode
+
+	// The difficulty of the AI when the recorded game started
+	Difficulty AIProfile
+
+	// The changes of the difficulty during the recorded game (e.g. from the pause menu), in the order they were made
+	DifficultyChanges []DifficultyChange
+
+	// The rules of the recorded game
+	Rules MatchRules
+
+	// The configuration of the recorded game (the physics must be the same to play it back)
+	Config Config
+
+	// The number of frames that were recorded
+	Frames int
+
+	// Whether the player's and the enemy's paddle were controlled by the AI
+	PlayerAI bool
+	EnemyAI  bool
+
+	// The input of both paddles, one byte per frame (see encodeInput)
+	Inputs []byte
+
+	// The checksum of the game state, every checkpointInterval frames
+	Checkpoints []Checkpoint
+}
+
+// Checkpoint is the checksum of the game state right after a frame has been updated
+type Checkpoint struct {
+	Frame    int
+	Checksum uint32
+}
+
+// DifficultyChange is a change of the difficulty of the AI in the middle of a recorded game
+type DifficultyChange struct {
+	// The number of frames played before the change
+	Frame int
+
+	// The new difficulty
+	Profile AIProfile
+}
+
+// newReplay creates an empty replay for a game with the given seed
+func newReplay(seed int64) *Replay {
+	return &Replay{Version: replayVersion, Seed: seed, Config: cfg}
+}
+
+// encodeInput packs the input of a single frame into the 4 lowest bits of a byte.
+// Only how fast it moves the paddle is kept (see PaddleInput.level), as a signed 4 bits number.
+func encodeInput(in PaddleInput) byte {
+	return byte(in.level()) & 0x0f
+}
+
+// decodeInput unpacks the input of a single frame from the 4 lowest bits of a byte,
+// as an analog control that moves the paddle exactly like the recorded input
+func decodeInput(b byte) PaddleInput {
+	level := int(b & 0x0f)
+	if level > analogSteps {
+		level -= 16
+	}
+	return PaddleInput{Analog: level}
+}
+
+// recordInput returns an input source that records every input returned by source
+// in the frame it was read at, shifted to the position of the paddle (see playerInputShift).
+func (r *Replay) recordInput(shift uint, source InputSource) InputSource {
+	return func(g *Game, p *Paddle) PaddleInput {
+		in := source(g, p)
+		// frames where the input was not read are recorded as no input
+		for len(r.Inputs) < g.frame {
+			r.Inputs = append(r.Inputs, 0)
+		}
+		r.Inputs[g.frame-1] |= encodeInput(in) << shift
+		return in
+	}
+}
+
+// playInput returns an input source that replays the input recorded at the position shift
+// for the current frame
+func (r *Replay) playInput(shift uint) InputSource {
+	return func(g *Game, _ *Paddle) PaddleInput {
+		if g.frame < 1 || g.frame > len(r.Inputs) {
+			return PaddleInput{}
+		}
+		return decodeInput(r.Inputs[g.frame-1] >> shift)
+	}
+}
+
+// controllers returns the controllers that replay the recorded match,
+// for the player's and the enemy's paddle
+func (r *Replay) controllers() (player, enemy Controller) {
+	player = newInputController(r.playInput(playerInputShift))
+	if r.PlayerAI {
+		player = newAIController(r.Difficulty)
+	}
+	enemy = newInputController(r.playInput(enemyInputShift))
+	if r.EnemyAI {
+		enemy = newAIController(r.Difficulty)
+	}
+	return player, enemy
+}
+
+// checkpoint records the number of frames of g and, every checkpointInterval frames, the checksum of its state
+func (r *Replay) checkpoint(g *Game) {
+	if g.frame == r.Frames {
+		// no frame was played (e.g. while choosing the difficulty)
+		return
+	}
+	r.Frames = g.frame
+	if g.frame%checkpointInterval == 0 {
+		r.Checkpoints = append(r.Checkpoints, Checkpoint{Frame: g.frame, Checksum: g.checksum()})
+	}
+}
+
+// currentDifficulty returns the difficulty of the AI after the last recorded change
+func (r *Replay) currentDifficulty() AIProfile {
+	if n := len(r.DifficultyChanges); n > 0 {
+		return r.DifficultyChanges[n-1].Profile
+	}
+	return r.Difficulty
+}
+
+// recordDifficulty records the difficulty of g if it was changed since the last frame played
+func (r *Replay) recordDifficulty(g *Game) {
+	switch n := len(r.DifficultyChanges); {
+	case g.difficulty == r.currentDifficulty():
+	case g.frame == 0:
+		// the game has not started yet
+		r.Difficulty = g.difficulty
+	case n > 0 && r.DifficultyChanges[n-1].Frame == g.frame:
+		// changed again before a frame was played
+		r.DifficultyChanges[n-1].Profile = g.difficulty
+	default:
+		r.DifficultyChanges = append(r.DifficultyChanges, DifficultyChange{Frame: g.frame, Profile: g.difficulty})
+	}
+}
+
+// playDifficulty changes the difficulty of g when it was changed in the recorded game, before the next frame is played
+func (r *Replay) playDifficulty(g *Game) {
+	for _, c := range r.DifficultyChanges {
+		if c.Frame == g.frame {
+			g.setDifficulty(c.Profile)
+		}
+	}
+}
+
+// Save writes the replay to a gzip compressed file
+func (r *Replay) Save(path string) error {
+	for len(r.Inputs) < r.Frames {
+		r.Inputs = append(r.Inputs, 0)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	if err := gob.NewEncoder(zw).Encode(r); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// loadReplay reads a replay that was written by Replay.Save
+func loadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a replay: %w", path, err)
+	}
+
+	r := &Replay{}
+	if err := gob.NewDecoder(zr).Decode(r); err != nil {
+		return nil, fmt.Errorf("%s is not a replay: %w", path, err)
+	}
+	if r.Version < replayVersion {
+		return nil, fmt.Errorf("%s was recorded with the physics of an older version (replay version %d), it cannot be played back", path, r.Version)
+	}
+	if r.Version > replayVersion {
+		return nil, fmt.Errorf("%s was recorded with a newer version (replay version %d, expected %d)", path, r.Version, replayVersion)
+	}
+
+	return r, nil
+}
+
+// checksum returns a hash of the whole state of the game that affects the simulation
+func (g *Game) checksum() uint32 {
+	values := []uint64{
+		uint64(g.frame),
+		uint64(g.state),
+		uint64(g.turn),
+		uint64(g.volleyCount),
+		uint64(g.score.player),
+		uint64(g.score.enemy),
+		g.rng.state,
+		math.Float64bits(g.ball.pos.X),
+		math.Float64bits(g.ball.pos.Y),
+		math.Float64bits(g.ball.velocity.X),
+		math.Float64bits(g.ball.velocity.Y),
+	}
+	if g.rules.Sets > 1 {
+		// only matches with sets have set scores
+		values = append(values, uint64(g.score.playerSets), uint64(g.score.enemySets))
+	}
+	if g.rules.Spin {
+		values = append(values, math.Float64bits(g.ball.spin))
+	}
+	for _, p := range []*Paddle{g.player.paddle, g.enemy.paddle} {
+		values = append(values,
+			math.Float64bits(p.pos.X), math.Float64bits(p.pos.Y),
+			math.Float64bits(p.velocity.X), math.Float64bits(p.velocity.Y))
+	}
+	if g.rules.PowerUps {
+		values = append(values, uint64(g.powerUpClock),
+			uint64(g.player.paddle.position.Height), uint64(g.enemy.paddle.position.Height))
+		for _, p := range g.powerUps {
+			values = append(values, uint64(p.kind), uint64(p.position.X), uint64(p.position.Y), uint64(p.left))
+		}
+		for _, e := range g.effects {
+			values = append(values, uint64(e.kind), uint64(e.side), uint64(e.left))
+		}
+		for _, b := range g.balls() {
+			values = append(values, uint64(b.hitter), uint64(b.held), math.Float64bits(b.heldOffset))
+		}
+		for _, b := range g.extraBalls {
+			values = append(values,
+				math.Float64bits(b.pos.X), math.Float64bits(b.pos.Y),
+				math.Float64bits(b.velocity.X), math.Float64bits(b.velocity.Y), math.Float64bits(b.spin))
+		}
+	}
+	for _, c := range []Controller{g.player.controller, g.enemy.controller} {
+		if ai, ok := c.(*AIController); ok {
+			values = append(values,
+				uint64(ai.randomPosition), uint64(len(ai.seen)),
+				math.Float64bits(ai.predictionError), math.Float64bits(ai.velocityNoise))
+			if ai.missing {
+				values = append(values, 1)
+			}
+		}
+	}
+
+	h := fnv.New32a()
+	_ = binary.Write(h, binary.LittleEndian, values)
+	return h.Sum32()
+}
+
+// Recorder is a game that records a replay of itself while it is played.
+// When the match is restarted (e.g. a rematch), the replay records the new match instead.
+type Recorder struct {
+	*Game
+
+	// The replay being recorded
+	replay *Replay
+
+	// The controllers whose input is being recorded
+	player, enemy Controller
+}
+
+// newRecorder starts recording the input of the paddles and the checkpoints of g.
+// The controllers of g must be bound before the recording starts,
+// and only the input of an InputController can be recorded.
+func newRecorder(g *Game) *Recorder {
+	r := &Recorder{Game: g, replay: newReplay(g.seed)}
+	r.bind()
+	return r
+}
+
+// bind records the input of the controllers of the game that are not recorded yet
+func (r *Recorder) bind() {
+	r.replay.Mode = r.Game.mode
+	r.replay.Rules = r.Game.rules
+	r.replay.Difficulty = r.Game.difficulty
+	r.replay.PlayerAI = r.record(&r.player, r.Game.player.controller, playerInputShift)
+	r.replay.EnemyAI = r.record(&r.enemy, r.Game.enemy.controller, enemyInputShift)
+}
+
+// record records the input of the controller c, unless it is already the recorded one,
+// and returns true if c is the AI (which is replayed by the AI instead)
+func (r *Recorder) record(recorded *Controller, c Controller, shift uint) (ai bool) {
+	if c != *recorded {
+		*recorded = c
+		if c, ok := c.(*InputController); ok {
+			c.source = r.replay.recordInput(shift, c.source)
+		}
+	}
+	_, ai = c.(*AIController)
+	return ai
+}
+
+// Update updates the game and records a checkpoint of its state.
+// The difficulty is recorded before the game is updated, as it can only change while no frame is played.
+func (r *Recorder) Update() error {
+	r.replay.recordDifficulty(r.Game)
+	if err := r.Game.Update(); err != nil {
+		return err
+	}
+	if r.Game.frame < r.replay.Frames {
+		// the match was restarted, record the new one instead
+		*r.replay = *newReplay(r.Game.seed)
+		r.bind()
+	}
+	r.replay.checkpoint(r.Game)
+	return nil
+}
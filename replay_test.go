@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+// TestReplayDifficultyChange records a match where the difficulty is changed in the middle,
+// and checks that it plays back without desync
+func TestReplayDifficultyChange(t *testing.T) {
+	useConfig(t, defaultConfig())
+
+	g := newSimulation(3)
+	g.player.controller = newAIController(defaultAIProfile)
+	recorder := newRecorder(g)
+	for _, change := range []struct {
+		frame      int
+		difficulty string
+	}{{600, "insane"}, {1200, "easy"}, {1800, "hard"}} {
+		for g.frame < change.frame {
+			if err := recorder.Update(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		g.setDifficulty(aiProfiles[change.difficulty])
+	}
+	for g.frame < 2400 {
+		if err := recorder.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replay := recorder.replay
+	if len(replay.DifficultyChanges) != 3 {
+		t.Fatalf("%d difficulty changes recorded, expected 3", len(replay.DifficultyChanges))
+	}
+	v := newReplayPlayback(replay)
+	for v.game.frame < replay.Frames {
+		if err := v.step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v.desyncFrame != 0 {
+		t.Errorf("replay desync at frame %d", v.desyncFrame)
+	}
+	if v.game.difficulty.Name != "hard" {
+		t.Errorf("replay ended with the %s difficulty, expected hard", v.game.difficulty.Name)
+	}
+}
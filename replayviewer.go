@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// ReplayViewer plays back a replay, frame by frame, substituting the recorded input for the keyboard.
+// It can be paused (space), stepped one frame at a time while paused (right arrow),
+// and sped up to 2x or 4x (keys 1, 2 and 4).
+type ReplayViewer struct {
+	// The game being replayed
+	game *Game
+
+	// The replay being played back
+	replay *Replay
+
+	// Whether the playback is paused
+	paused bool
+
+	// The number of frames played back per update
+	speed int
+
+	// The index of the next checkpoint to verify
+	nextCheckpoint int
+
+	// The frame where the game state stopped matching the replay (0 if it never happened)
+	desyncFrame int
+}
+
+// newReplayViewer creates a game that plays back the replay in a window, with sounds
+func newReplayViewer(replay *Replay) *ReplayViewer {
+	hud, err := newHUD()
+	if err != nil {
+		log.Fatal(err)
+	}
+	v := newReplayPlayback(replay)
+	v.game.hud = hud
+	v.game.ball.loadSounds()
+	return v
+}
+
+// newReplayPlayback creates the playback of the replay, without HUD or sounds (see newReplayViewer).
+// The game is not local: the playback has its own pause.
+func newReplayPlayback(replay *Replay) *ReplayViewer {
+	g := newSimulation(replay.Seed)
+	g.mode = replay.Mode
+	g.rules = replay.Rules
+	g.player.controller, g.enemy.controller = replay.controllers()
+	g.setDifficulty(replay.Difficulty)
+
+	return &ReplayViewer{
+		game:   g,
+		replay: replay,
+		speed:  1,
+	}
+}
+
+// Update handles the playback controls and plays back as many frames as the speed requires
+func (v *ReplayViewer) Update() error {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		v.paused = !v.paused
+	case inpututil.IsKeyJustPressed(ebiten.Key1):
+		v.speed = 1
+	case inpututil.IsKeyJustPressed(ebiten.Key2):
+		v.speed = 2
+	case inpututil.IsKeyJustPressed(ebiten.Key4):
+		v.speed = 4
+	}
+
+	frames := v.speed
+	if v.paused {
+		frames = 0
+		if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+			frames = 1
+		}
+	}
+
+	for i := 0; i < frames && v.game.frame < v.replay.Frames; i++ {
+		if err := v.step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// step plays back a single frame and verifies the state of the game against the checkpoints of the replay.
+// The playback is paused when the game state does not match the recorded one.
+func (v *ReplayViewer) step() error {
+	v.replay.playDifficulty(v.game)
+	if err := v.game.Update(); err != nil {
+		return err
+	}
+
+	checkpoints := v.replay.Checkpoints
+	for v.nextCheckpoint < len(checkpoints) && checkpoints[v.nextCheckpoint].Frame <= v.game.frame {
+		checkpoint := checkpoints[v.nextCheckpoint]
+		v.nextCheckpoint++
+
+		if checkpoint.Frame == v.game.frame && checkpoint.Checksum != v.game.checksum() && v.desyncFrame == 0 {
+			v.desyncFrame = v.game.frame
+			v.paused = true
+			log.Printf("replay desync at frame %d", v.desyncFrame)
+		}
+	}
+
+	return nil
+}
+
+// Draw draws the game being replayed and the playback status
+func (v *ReplayViewer) Draw(screen *ebiten.Image) {
+	v.game.Draw(screen)
+
+	status := fmt.Sprintf("REPLAY  frame %d/%d  %dx", v.game.frame, v.replay.Frames, v.speed)
+	if v.paused {
+		status += "  PAUSED (right arrow: next frame)"
+	}
+	if v.game.frame >= v.replay.Frames {
+		status += "  END"
+	}
+	if v.desyncFrame != 0 {
+		status += fmt.Sprintf("  DESYNC at frame %d", v.desyncFrame)
+	}
+	ebitenutil.DebugPrintAt(screen, status, 10, screenHeight-20)
+}
+
+// Layout returns the size of the game screen
+func (v *ReplayViewer) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return v.game.Layout(outsideWidth, outsideHeight)
+}
@@ -0,0 +1,62 @@
+package main
+
+import "time"
+
+// unusedInput marks a tick where the remote input was not read (e.g. during the service)
+const unusedInput byte = 0xff
+
+// When the local game runs ahead of the other player, it waits one tick every frameSkipInterval ticks
+const frameSkipInterval = 10
+
+// tickDuration is the time between two ticks (60 per second)
+const tickDuration = time.Second / 60
+
+// rollBack compares the remote input of the ticks simulated with a prediction to the real input received since.
+// The game is rolled back to the first tick that was mispredicted, and simulated again up to the current tick.
+func (s *NetSession) rollBack() error {
+	for {
+		last := s.game.frame
+		if len(s.remoteInputs) < last {
+			last = len(s.remoteInputs)
+		}
+		if s.verified >= last {
+			return nil
+		}
+
+		tick := s.verified + 1
+		if used := s.remoteUsed[tick-1]; used != unusedInput && used != s.remoteInputs[tick-1] {
+			if err := s.resimulate(tick); err != nil {
+				return err
+			}
+			continue
+		}
+		s.verified = tick
+	}
+}
+
+// resimulate restores the game to the state before the tick from, and simulates again up to the current tick
+func (s *NetSession) resimulate(from int) error {
+	to := s.game.frame
+
+	// the sounds of these ticks were already played, mute every ball (restore gives the extra balls the sounds of the ball)
+	sounds := s.game.ball.sounds
+	s.game.setSounds(nil)
+	defer func() { s.game.setSounds(sounds) }()
+
+	s.game.restore(s.snapshots[(from-1)%len(s.snapshots)])
+	s.rollbacks++
+
+	for s.game.frame < to {
+		if err := s.step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aheadOfPeer estimates by how many ticks the local game runs ahead of the game of the other player.
+// Its last input received was read inputDelay ticks ahead of its game, and took half the ping to arrive.
+func (s *NetSession) aheadOfPeer() int {
+	peerTick := len(s.remoteInputs) - s.inputDelay + int(s.ping/2/tickDuration)
+	return s.game.frame - peerTick
+}
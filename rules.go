@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// MatchRules decide who wins a match, and when
+type MatchRules struct {
+	// The name of the rules (e.g. "classic")
+	Name string
+
+	// The points needed to win a set (0 means no limit, the match is then decided by the time limit)
+	PointsToWin int
+
+	// Whether a set must be won by two points (deuce): after a tie at PointsToWin-1, the set goes on
+	// until a player leads by two points
+	WinByTwo bool
+
+	// The number of sets of the match (best of N): the first player to win more than half of them wins
+	Sets int
+
+	// The duration of the match (0 means no limit). When the time is up, the player ahead wins
+	// (most sets won, then most points in the current set), and if it is a tie the next point wins (sudden death).
+	TimeLimit time.Duration
+
+	// Whether the paddles make the ball spin and curve (see SpinConfig)
+	Spin bool
+
+	// Whether the paddles accelerate and slow down instead of starting and stopping right away,
+	// and how fast they do it (the momentum section of the config, for the presets)
+	Momentum       bool
+	MomentumLimits MomentumConfig
+
+	// Whether power-ups appear on the court (see PowerUpConfig), the arcade mode
+	PowerUps bool
+}
+
+// matchRules are the presets of the match rules
+var matchRules = map[string]MatchRules{
+	"classic":   {Name: "classic", PointsToWin: pointsToWin, Sets: 1},
+	"quick":     {Name: "quick", PointsToWin: 5, Sets: 1},
+	"deuce":     {Name: "deuce", PointsToWin: 11, WinByTwo: true, Sets: 1},
+	"best-of-5": {Name: "best-of-5", PointsToWin: 11, WinByTwo: true, Sets: 5},
+	"timed":     {Name: "timed", Sets: 1, TimeLimit: 3 * time.Minute},
+}
+
+// matchRulesNames lists the names of the presets of the match rules, in the order of the menu
+var matchRulesNames = []string{"classic", "quick", "deuce", "best-of-5", "timed"}
+
+// defaultMatchRules are the rules used when none are chosen
+var defaultMatchRules = matchRules["classic"]
+
+// lookupMatchRules returns the preset of the match rules with the given name
+func lookupMatchRules(name string) (MatchRules, error) {
+	rules, ok := matchRules[name]
+	if !ok {
+		return MatchRules{}, fmt.Errorf("unknown rules %q (expected one of %v)", name, matchRulesNames)
+	}
+	return rules, nil
+}
+
+// CustomRulesConfig are the rules of the match when the rules are "custom" (the points, the sets and the time limit,
+// spin, momentum and power-ups are chosen with any rules)
+type CustomRulesConfig struct {
+	// The points needed to win a set (0 for no limit, with a time limit)
+	PointsToWin int `json:"pointsToWin"`
+
+	// Whether a set must be won by two points
+	WinByTwo bool `json:"winByTwo"`
+
+	// The number of sets of the match
+	Sets int `json:"sets"`
+
+	// The duration of the match (e.g. "3m", "0s" for no limit)
+	TimeLimit Duration `json:"timeLimit"`
+}
+
+// defaultCustomRules returns the custom rules before they are changed, the classic ones
+func defaultCustomRules() CustomRulesConfig {
+	return CustomRulesConfig{PointsToWin: pointsToWin, Sets: 1}
+}
+
+// rules returns the match rules named custom
+func (c CustomRulesConfig) rules() MatchRules {
+	return MatchRules{Name: "custom", PointsToWin: c.PointsToWin, WinByTwo: c.WinByTwo, Sets: c.Sets, TimeLimit: time.Duration(c.TimeLimit)}
+}
+
+// validate returns an error if the custom rules make no sense
+func (c CustomRulesConfig) validate() error {
+	if err := c.rules().validate(); err != nil {
+		return fmt.Errorf("customRules: %w", err)
+	}
+	return nil
+}
+
+// validate returns an error if the rules make no sense
+func (r MatchRules) validate() error {
+	switch {
+	case r.PointsToWin < 0:
+		return fmt.Errorf("points to win cannot be negative, got %d", r.PointsToWin)
+	case r.Sets < 1:
+		return fmt.Errorf("a match has at least 1 set, got %d", r.Sets)
+	case r.TimeLimit < 0:
+		return fmt.Errorf("time limit cannot be negative, got %v", r.TimeLimit)
+	case r.PointsToWin == 0 && r.TimeLimit == 0:
+		return fmt.Errorf("a match needs points to win or a time limit, otherwise it never ends")
+	case r.PointsToWin == 0 && r.Sets > 1:
+		return fmt.Errorf("a match with %d sets needs points to win each set", r.Sets)
+	}
+	if r.Momentum {
+		return r.MomentumLimits.validate()
+	}
+	return nil
+}
+
+// String describes the rules (e.g. "11 POINTS, WIN BY 2, BEST OF 5")
+func (r MatchRules) String() string {
+	s := ""
+	if r.PointsToWin > 0 {
+		s = fmt.Sprintf("%d POINTS", r.PointsToWin)
+	}
+	if r.WinByTwo {
+		s += ", WIN BY 2"
+	}
+	if r.Sets > 1 {
+		s += fmt.Sprintf(", BEST OF %d", r.Sets)
+	}
+	if r.TimeLimit > 0 {
+		if s != "" {
+			s += ", "
+		}
+		s += formatDuration(r.TimeLimit) + " MIN"
+	}
+	if r.Spin {
+		s += ", SPIN"
+	}
+	if r.Momentum {
+		s += ", MOMENTUM"
+	}
+	if r.PowerUps {
+		s += ", POWER-UPS"
+	}
+	return s
+}
+
+// setsToWin returns the number of sets a player must win to win the match
+func (r MatchRules) setsToWin() int {
+	return r.Sets/2 + 1
+}
+
+// wonSet returns true if a player with these points has won the set against the other player
+func (r MatchRules) wonSet(points, other int) bool {
+	if r.PointsToWin == 0 || points < r.PointsToWin {
+		return false
+	}
+	return !r.WinByTwo || points-other >= 2
+}
+
+// timeUp returns true if the match has a time limit and it is over
+func (g *Game) timeUp() bool {
+	return g.rules.TimeLimit > 0 && g.matchDuration() >= g.rules.TimeLimit
+}
+
+// timeLeft returns how much time is left before the time limit
+func (g *Game) timeLeft() time.Duration {
+	if left := g.rules.TimeLimit - g.matchDuration(); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// lead returns who is ahead in the match: 1 for the player, -1 for the enemy, 0 for a tie.
+// The sets won count first, then the points of the current set.
+func (g *Game) lead() int {
+	if s := g.score; s.playerSets != s.enemySets {
+		return sign(s.playerSets - s.enemySets)
+	}
+	return sign(g.score.player - g.score.enemy)
+}
+
+// suddenDeath returns true if the time is up with a tie, so that the next point wins the match
+func (g *Game) suddenDeath() bool {
+	return g.timeUp() && g.lead() == 0
+}
+
+// checkTimeLimit ends the match when the time is up, unless it is a tie
+func (g *Game) checkTimeLimit() {
+	if g.timeUp() && g.lead() != 0 {
+		g.state = gameOver
+	}
+}
+
+// winner returns the name of the side that won the match, or that is ahead
+func (g *Game) winner() string {
+	enemyName, playerName := g.names()
+	if g.lead() > 0 {
+		return playerName
+	}
+	return enemyName
+}
+
+// sign returns 1 if n is positive, -1 if it is negative and 0 otherwise
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
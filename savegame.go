@@ -0,0 +1,374 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/drpaneas/rect"
+)
+
+// saveVersion is the version of the saved match file format.
+// Version 2 added the longest volley of the match, version 3 the rules and the sets won,
+// version 4 the sub-pixel positions of the ball and the paddles, version 5 the hash of the configuration.
+const saveVersion = 5
+
+// saveMigrations upgrade a saved match from one version to the next: saveMigrations[v-1] upgrades version v to v+1.
+// The fields added by a version are missing from the files saved by the older ones (gob leaves them empty),
+// so the migration to that version fills them in.
+var saveMigrations = []func(m *SavedMatch){
+	// 1 -> 2: the longest volley was not saved, the current one is the best guess
+	func(m *SavedMatch) {
+		m.LongestVolley = m.VolleyCount
+	},
+	// 2 -> 3: the matches were played with the classic rules, in a single set
+	func(m *SavedMatch) {
+		m.Rules = defaultMatchRules
+	},
+	// 3 -> 4: the positions were whole numbers of pixels
+	func(m *SavedMatch) {
+		for _, b := range []*SavedBody{&m.Ball, &m.Player, &m.Enemy} {
+			b.PosX, b.PosY = float64(b.X), float64(b.Y)
+		}
+	},
+	// 4 -> 5: the configuration was not saved, most matches were played with the default one
+	func(m *SavedMatch) {
+		m.ConfigHash = defaultConfig().hash()
+	},
+}
+
+// SavedMatch is a match in progress, saved when the window is closed so that it can be continued on the next launch
+type SavedMatch struct {
+	// The version of the file format
+	Version int
+
+	// The seed the match was started with, and the state of its random number generator
+	Seed int64
+	RNG  uint64
+
+	// The game mode and the difficulty of the AI
+	Mode       GameMode
+	Difficulty AIProfile
+
+	// The rules of the match (since version 3)
+	Rules MatchRules
+
+	// The hash of the configuration the match was played with (since version 5), it is only continued with the same one
+	ConfigHash uint32
+
+	// The state of the match
+	Frame       int
+	State       GameState
+	Turn        playerTurn
+	VolleyCount int
+	Score       SavedScore
+
+	// The highest volley count of the match (since version 2)
+	LongestVolley int
+
+	// The position and the velocity of the ball and the paddles
+	Ball, Player, Enemy SavedBody
+
+	// The spin of the ball (0 in the files of the versions where the ball could not spin)
+	BallSpin float64
+
+	// The power-ups of the match (nil without power-ups, and in the files of the versions without them)
+	PowerUps *SavedPowerUps
+
+	// The state of the AI of the paddles (nil if the paddle is not controlled by the AI).
+	// The older versions of the game saved the input of the previous frame too, which is not needed anymore
+	// (the velocity only depends on the input of the current frame) and is skipped when the file is read.
+	PlayerAI, EnemyAI *SavedAI
+}
+
+// SavedScore is the score of a saved match: the points of the current set, and the sets won (since version 3)
+type SavedScore struct {
+	Player, Enemy         int
+	PlayerSets, EnemySets int
+}
+
+// SavedBody is the position and the velocity of the ball or a paddle
+type SavedBody struct {
+	X, Y                 int
+	VelocityX, VelocityY float64
+
+	// The position with sub-pixel precision (since version 4), X and Y are the rounded position
+	PosX, PosY float64
+}
+
+// SavedPowerUps is the state of the power-ups of a saved match: the power-ups on the court, their effects,
+// what only matters to the ball with power-ups, and the extra balls
+type SavedPowerUps struct {
+	Clock      int
+	Items      []SavedPowerUp
+	Effects    []SavedEffect
+	Ball       SavedBall
+	ExtraBalls []SavedBall
+}
+
+// SavedPowerUp is a power-up waiting on the court
+type SavedPowerUp struct {
+	Kind PowerUpKind
+	X, Y int
+	Left int
+}
+
+// SavedEffect is a power-up acting on a side of the court
+type SavedEffect struct {
+	Kind           PowerUpKind
+	Side           Side
+	Left, Duration int
+}
+
+// SavedBall is a ball of a match with power-ups (only the fields set by applyState are read back for the ball,
+// its body and its spin are read from the saved match)
+type SavedBall struct {
+	Body       SavedBody
+	Spin       float64
+	Hitter     Side
+	Held       int
+	HeldOffset float64
+}
+
+// SavedAI is the state of the AI during the current attack or patrol.
+// What it has seen of the ball is not saved, it sees the ball again after its reaction time.
+type SavedAI struct {
+	RandomPosition  int
+	Approaching     bool
+	PredictionError float64
+	VelocityNoise   float64
+	Missing         bool
+}
+
+// defaultSavePath returns where the match in progress is saved, in the configuration directory of the user
+func defaultSavePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pong", "match.sav")
+}
+
+// inProgress returns true if the match has started and is not over
+func (g *Game) inProgress() bool {
+	return g.frame > 0 && g.state != gameOver
+}
+
+// saveMatch returns the state of g to be saved
+func (g *Game) saveMatch() *SavedMatch {
+	m := &SavedMatch{
+		Version:       saveVersion,
+		Seed:          g.seed,
+		RNG:           g.rng.state,
+		Mode:          g.mode,
+		Difficulty:    g.difficulty,
+		Rules:         g.rules,
+		ConfigHash:    cfg.hash(),
+		Frame:         g.frame,
+		State:         g.unpausedState(),
+		Turn:          g.turn,
+		VolleyCount:   g.volleyCount,
+		LongestVolley: g.longestVolley,
+		Ball:          saveBody(g.ball.pos, g.ball.velocity),
+		Player:        saveBody(g.player.paddle.pos, g.player.paddle.velocity),
+		Enemy:         saveBody(g.enemy.paddle.pos, g.enemy.paddle.velocity),
+		BallSpin:      g.ball.spin,
+		Score: SavedScore{
+			Player: g.score.player, Enemy: g.score.enemy,
+			PlayerSets: g.score.playerSets, EnemySets: g.score.enemySets,
+		},
+	}
+	m.PlayerAI = saveAI(g.player.controller)
+	m.EnemyAI = saveAI(g.enemy.controller)
+	if g.rules.PowerUps {
+		m.PowerUps = g.savePowerUps()
+	}
+	return m
+}
+
+func (g *Game) savePowerUps() *SavedPowerUps {
+	s := &SavedPowerUps{Clock: g.powerUpClock, Ball: saveBall(g.ball)}
+	for _, p := range g.powerUps {
+		s.Items = append(s.Items, SavedPowerUp{Kind: p.kind, X: p.position.X, Y: p.position.Y, Left: p.left})
+	}
+	for _, e := range g.effects {
+		s.Effects = append(s.Effects, SavedEffect{Kind: e.kind, Side: e.side, Left: e.left, Duration: e.duration})
+	}
+	for _, b := range g.extraBalls {
+		s.ExtraBalls = append(s.ExtraBalls, saveBall(b))
+	}
+	return s
+}
+
+func saveBall(b *Ball) SavedBall {
+	return SavedBall{
+		Body: saveBody(b.pos, b.velocity), Spin: b.spin,
+		Hitter: b.hitter, Held: b.held, HeldOffset: b.heldOffset,
+	}
+}
+
+// unpausedState returns the state of the match, or the state it was paused in
+func (g *Game) unpausedState() GameState {
+	if g.state == paused {
+		return g.resumeState
+	}
+	return g.state
+}
+
+func saveBody(pos, velocity Vector2D) SavedBody {
+	return SavedBody{
+		X: int(math.Round(pos.X)), Y: int(math.Round(pos.Y)),
+		VelocityX: velocity.X, VelocityY: velocity.Y,
+		PosX: pos.X, PosY: pos.Y,
+	}
+}
+
+func saveAI(c Controller) *SavedAI {
+	ai, ok := c.(*AIController)
+	if !ok {
+		return nil
+	}
+	return &SavedAI{
+		RandomPosition:  ai.randomPosition,
+		Approaching:     ai.approaching,
+		PredictionError: ai.predictionError,
+		VelocityNoise:   ai.velocityNoise,
+		Missing:         ai.missing,
+	}
+}
+
+// apply puts the game g in the state of the saved match.
+// The controllers of g are kept, and the AI gets back its saved state.
+func (m *SavedMatch) apply(g *Game) {
+	g.seed = m.Seed
+	g.rng.state = m.RNG
+	g.mode = m.Mode
+	g.setDifficulty(m.Difficulty)
+	g.rules = m.Rules
+	g.frame = m.Frame
+	g.state = m.State
+	g.turn = m.Turn
+	g.volleyCount = m.VolleyCount
+	g.longestVolley = m.LongestVolley
+	g.score = Score{player: m.Score.Player, enemy: m.Score.Enemy, playerSets: m.Score.PlayerSets, enemySets: m.Score.EnemySets}
+	m.Ball.apply(g.ball.moveTo, &g.ball.velocity)
+	m.Player.apply(g.player.paddle.moveTo, &g.player.paddle.velocity)
+	m.Enemy.apply(g.enemy.paddle.moveTo, &g.enemy.paddle.velocity)
+	// the controllers wanted the velocity the paddles had, unless they have momentum (they then want a velocity every frame)
+	g.player.paddle.target, g.enemy.paddle.target = g.player.paddle.velocity.Y, g.enemy.paddle.velocity.Y
+	g.ball.spin = m.BallSpin
+	loadAI(g.player.controller, m.PlayerAI)
+	loadAI(g.enemy.controller, m.EnemyAI)
+	if m.PowerUps != nil {
+		m.PowerUps.apply(g)
+	}
+}
+
+// apply puts the power-ups of g in the saved state
+func (s *SavedPowerUps) apply(g *Game) {
+	g.clearPowerUps()
+	g.powerUpClock = s.Clock
+	for _, p := range s.Items {
+		size := cfg.PowerUps.Size
+		g.powerUps = append(g.powerUps, &PowerUp{kind: p.Kind, position: *rect.Rect(p.X, p.Y, size, size), left: p.Left})
+	}
+	for _, e := range s.Effects {
+		g.effects = append(g.effects, Effect{kind: e.Kind, side: e.Side, left: e.Left, duration: e.Duration})
+	}
+	s.Ball.applyState(g.ball)
+	for _, saved := range s.ExtraBalls {
+		b := newBall()
+		b.sounds = g.ball.sounds
+		saved.Body.apply(b.moveTo, &b.velocity)
+		b.spin = saved.Spin
+		saved.applyState(b)
+		g.extraBalls = append(g.extraBalls, b)
+	}
+
+	// the saved positions are the ones of the paddles resized by the effects
+	for _, side := range []Side{playerSide, enemySide} {
+		g.paddleOf(side).position.Height = g.paddleHeight(side)
+	}
+	g.updateObjects()
+}
+
+// applyState puts the ball b in the saved state it only has with power-ups
+func (s SavedBall) applyState(b *Ball) {
+	b.hitter, b.held, b.heldOffset = s.Hitter, s.Held, s.HeldOffset
+}
+
+func (b SavedBody) apply(moveTo func(x, y float64), velocity *Vector2D) {
+	moveTo(b.PosX, b.PosY)
+	*velocity = Vector2D{X: b.VelocityX, Y: b.VelocityY}
+}
+
+func loadAI(c Controller, saved *SavedAI) {
+	ai, ok := c.(*AIController)
+	if !ok || saved == nil {
+		return
+	}
+	ai.randomPosition = saved.RandomPosition
+	ai.approaching = saved.Approaching
+	ai.predictionError = saved.PredictionError
+	ai.velocityNoise = saved.VelocityNoise
+	ai.missing = saved.Missing
+}
+
+// Save writes the saved match to a gzip compressed file, creating its directory if needed
+func (m *SavedMatch) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	if err := gob.NewEncoder(zw).Encode(m); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// loadSavedMatch reads a match that was written by SavedMatch.Save, and migrates it to the current version
+func loadSavedMatch(path string) (*SavedMatch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a saved match: %w", path, err)
+	}
+
+	m := &SavedMatch{}
+	if err := gob.NewDecoder(zr).Decode(m); err != nil {
+		return nil, fmt.Errorf("%s is not a saved match: %w", path, err)
+	}
+	if m.Version < 1 || m.Version > saveVersion {
+		return nil, fmt.Errorf("%s has unsupported saved match version %d (expected at most %d)", path, m.Version, saveVersion)
+	}
+	for m.Version < saveVersion {
+		saveMigrations[m.Version-1](m)
+		m.Version++
+	}
+	if m.Rules.Momentum && m.Rules.MomentumLimits == (MomentumConfig{}) {
+		// saved before the rules had the momentum of the paddles
+		m.Rules.MomentumLimits = cfg.Momentum
+	}
+	if m.ConfigHash != cfg.hash() {
+		return nil, fmt.Errorf("%s was saved with another configuration (-config and the flags), it cannot be continued with this one", path)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestSavedMatchConfig checks that a saved match is only continued with the configuration it was saved with
+// (apart from the controls, each player sets their own)
+func TestSavedMatchConfig(t *testing.T) {
+	saveConfig := defaultConfig()
+	otherConfig := defaultConfig()
+	otherConfig.Paddle.Height += 20
+	controlsConfig := defaultConfig()
+	controlsConfig.Controls.Pause.Keys = controlsConfig.Controls.Pause.Keys[:1]
+
+	tests := []struct {
+		name   string
+		config Config // when the match is continued
+		ok     bool
+	}{
+		{"same config", saveConfig, true},
+		{"other controls", controlsConfig, true},
+		{"other paddle height", otherConfig, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, saveConfig)
+			g := newSimulation(1)
+			g.frame = 100
+			path := filepath.Join(t.TempDir(), "match.sav")
+			if err := g.saveMatch().Save(path); err != nil {
+				t.Fatal(err)
+			}
+
+			useConfig(t, tt.config)
+			if _, err := loadSavedMatch(path); (err == nil) != tt.ok {
+				t.Errorf("loading the saved match returned %v, expected ok %t", err, tt.ok)
+			}
+		})
+	}
+}
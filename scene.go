@@ -0,0 +1,87 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// transitionFrames is the number of frames it takes to fade out of a scene, and then into the next one
+const transitionFrames = 15
+
+// Scene is a screen of the game (the title, a menu, the match, etc).
+// Only the current scene of the SceneManager is updated and drawn.
+type Scene interface {
+	// Update updates the scene, it can go to another scene with sm.GoTo
+	Update(sm *SceneManager) error
+
+	// Draw draws the scene on the screen
+	Draw(screen *ebiten.Image)
+}
+
+// SceneManager runs the current scene, and fades to black and back when going to another one.
+// The scenes are not updated during a transition.
+type SceneManager struct {
+	// The scene being played
+	current Scene
+
+	// The scene to go to at the end of the fade out (nil if there is no transition)
+	next Scene
+
+	// The frames left before the end of the fade out (positive) or fade in (negative)
+	transition int
+}
+
+// newSceneManager creates a scene manager that starts with the scene first, without a transition
+func newSceneManager(first Scene) *SceneManager {
+	return &SceneManager{current: first}
+}
+
+// GoTo fades out of the current scene, then into the scene next
+func (sm *SceneManager) GoTo(next Scene) {
+	if sm.next != nil {
+		// already leaving the current scene
+		return
+	}
+	sm.next = next
+	sm.transition = transitionFrames
+}
+
+// Update runs the transition if there is one, otherwise it updates the current scene
+func (sm *SceneManager) Update() error {
+	switch {
+	case sm.transition > 1:
+		sm.transition--
+		return nil
+	case sm.transition == 1:
+		sm.current, sm.next = sm.next, nil
+		sm.transition = -transitionFrames
+		return nil
+	case sm.transition < 0:
+		sm.transition++
+		return nil
+	}
+	return sm.current.Update(sm)
+}
+
+// Draw draws the current scene, covered by black as much as the transition requires
+func (sm *SceneManager) Draw(screen *ebiten.Image) {
+	sm.current.Draw(screen)
+
+	if sm.transition == 0 {
+		return
+	}
+	// from transparent to black while fading out, and the other way round while fading in
+	progress := float64(transitionFrames-sm.transition) / transitionFrames
+	if sm.transition < 0 {
+		progress = float64(-sm.transition) / transitionFrames
+	}
+	black := color.RGBA{A: uint8(255 * progress)}
+	vector.DrawFilledRect(screen, 0, 0, float32(screenWidth), float32(screenHeight), black)
+}
+
+// Layout returns the size of the game screen
+func (sm *SceneManager) Layout(_, _ int) (int, int) {
+	return screenWidth, screenHeight
+}
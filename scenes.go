@@ -0,0 +1,463 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// MatchConfig holds how the matches are set up, from the command line and the menus
+type MatchConfig struct {
+	// The seed of the first match, the next ones get a seed picked from it
+	Seed int64
+
+	// The game mode of the next match
+	Mode GameMode
+
+	// The names of the controllers of the left and right paddles (see newController),
+	// an empty name picks the default of the game mode (cpu or Player2 on the left, keyboard on the right)
+	Left, Right string
+
+	// The controller of player 2 on the left paddle in two players mode, when Left is empty (empty for ws)
+	Player2 string
+
+	// The difficulty of the AI
+	Difficulty AIProfile
+
+	// Whether the difficulty was chosen on the command line, so that it is not asked before a match
+	DifficultyChosen bool
+
+	// The rules of the matches
+	Rules MatchRules
+
+	// Show debug information during the match
+	Debug bool
+
+	// The file where the replay of the last match is recorded (empty to not record)
+	RecordPath string
+
+	// The file where the match in progress is saved on exit (empty to not save)
+	SavePath string
+}
+
+// App is the game played in a window: the scenes, and what they share
+type App struct {
+	// The scenes of the game
+	scenes *SceneManager
+
+	// How the matches are set up
+	config MatchConfig
+
+	// The HUD used to draw the text of all the scenes
+	hud *HUD
+
+	// The match being played (nil before the first one)
+	match *MatchScene
+}
+
+// newApp creates the game, starting on the title screen
+func newApp(config MatchConfig) (*App, error) {
+	hud, err := newHUD()
+	if err != nil {
+		return nil, err
+	}
+	a := &App{config: config, hud: hud}
+	a.scenes = newSceneManager(a.titleScene())
+	return a, nil
+}
+
+// exit saves the match in progress (unless the players quit it) and the replay of the last match, when the window is closed
+func (a *App) exit() {
+	if a.match == nil {
+		return
+	}
+	if a.config.SavePath != "" && a.match.game.inProgress() && !a.match.left {
+		if err := a.match.game.saveMatch().Save(a.config.SavePath); err != nil {
+			log.Printf("cannot save the match: %v", err)
+		}
+	}
+	if a.match.recorder != nil {
+		if err := a.match.recorder.replay.Save(a.config.RecordPath); err != nil {
+			log.Printf("cannot save the replay: %v", err)
+		}
+	}
+}
+
+// titleScene is the first scene: the title and the main menu
+func (a *App) titleScene() Scene {
+	m := newMenu("PONG")
+
+	// a replay has to start from the beginning of the match, so a saved match is continued only when not recording
+	if a.config.RecordPath == "" {
+		if saved, err := loadSavedMatch(a.config.SavePath); err == nil {
+			score := Score{player: saved.Score.Player, enemy: saved.Score.Enemy}
+			m.items = append(m.items, MenuItem{
+				label:  fmt.Sprintf("CONTINUE MATCH (%s)", score),
+				action: func() { a.continueMatch(saved) },
+			})
+		} else if !errors.Is(err, os.ErrNotExist) && a.config.SavePath != "" {
+			log.Printf("cannot continue the saved match: %v", err)
+		}
+	}
+
+	m.items = append(m.items,
+		MenuItem{label: "NEW MATCH", action: func() { a.scenes.GoTo(a.modeScene()) }},
+		MenuItem{label: "SETTINGS", action: func() { a.scenes.GoTo(a.settingsScene(a.titleScene)) }},
+		MenuItem{label: "CREDITS", action: func() { a.scenes.GoTo(a.creditsScene()) }},
+		MenuItem{label: "QUIT", action: func() { a.scenes.GoTo(quitScene{}) }},
+	)
+	return a.menuScene(m, nil)
+}
+
+// modeScene chooses the game mode of the new match
+func (a *App) modeScene() Scene {
+	choose := func(mode GameMode) func() {
+		return func() {
+			a.config.Mode = mode
+			if mode == onePlayer && !a.config.DifficultyChosen {
+				a.scenes.GoTo(a.difficultyScene())
+				return
+			}
+			a.startMatch()
+		}
+	}
+	m := newMenu("NEW MATCH",
+		MenuItem{label: "1 PLAYER", action: choose(onePlayer)},
+		MenuItem{label: "2 PLAYERS", action: choose(twoPlayers)},
+		MenuItem{label: rulesLabel(a.config.Rules)},
+		MenuItem{label: onOff("SPIN", a.config.Rules.Spin)},
+		MenuItem{label: onOff("MOMENTUM", a.config.Rules.Momentum)},
+		MenuItem{label: onOff("POWER-UPS", a.config.Rules.PowerUps)},
+		MenuItem{label: controlsLabel(1, a.config.Right)},
+		MenuItem{label: controlsLabel(2, a.config.Player2)},
+		MenuItem{label: "BACK", action: func() { a.scenes.GoTo(a.titleScene()) }},
+	)
+	m.info = []string{a.config.Rules.String()}
+	m.items[2].action = func() {
+		// go to the next preset, and back to the first after the last (custom rules go to the first)
+		next := 0
+		for i, name := range matchRulesNames {
+			if name == a.config.Rules.Name {
+				next = (i + 1) % len(matchRulesNames)
+			}
+		}
+		rules := a.config.Rules
+		a.config.Rules = matchRules[matchRulesNames[next]]
+		a.config.Rules.Spin, a.config.Rules.Momentum, a.config.Rules.PowerUps = rules.Spin, rules.Momentum, rules.PowerUps
+		m.items[2].label = rulesLabel(a.config.Rules)
+		m.info[0] = a.config.Rules.String()
+	}
+	m.items[3].action = func() {
+		a.config.Rules.Spin = !a.config.Rules.Spin
+		m.items[3].label = onOff("SPIN", a.config.Rules.Spin)
+		m.info[0] = a.config.Rules.String()
+	}
+	m.items[4].action = func() {
+		a.config.Rules.Momentum = !a.config.Rules.Momentum
+		m.items[4].label = onOff("MOMENTUM", a.config.Rules.Momentum)
+		m.info[0] = a.config.Rules.String()
+	}
+	m.items[5].action = func() {
+		a.config.Rules.PowerUps = !a.config.Rules.PowerUps
+		m.items[5].label = onOff("POWER-UPS", a.config.Rules.PowerUps)
+		m.info[0] = a.config.Rules.String()
+	}
+	m.items[6].action = func() {
+		a.config.Right = nextControls(a.config.Right, "keyboard")
+		m.items[6].label = controlsLabel(1, a.config.Right)
+	}
+	m.items[7].action = func() {
+		a.config.Player2 = nextControls(a.config.Player2, "")
+		m.items[7].label = controlsLabel(2, a.config.Player2)
+	}
+	m.selected = int(a.config.Mode)
+	return a.menuScene(m, nil)
+}
+
+// nextControls returns the controls a player gets after the controls named name (see newController),
+// going from the keys (named keys) to the mouse, then to touch and back to the keys
+func nextControls(name, keys string) string {
+	switch name {
+	case "mouse":
+		return "touch"
+	case "touch":
+		return keys
+	}
+	return "mouse"
+}
+
+// controlsLabel returns the label of the menu item choosing the controls of the player
+func controlsLabel(player int, name string) string {
+	switch name {
+	case "", "keyboard", "ws":
+		name = "keys"
+	}
+	return fmt.Sprintf("P%d CONTROLS: %s", player, strings.ToUpper(name))
+}
+
+// rulesLabel returns the label of the menu item choosing the rules
+func rulesLabel(rules MatchRules) string {
+	return "RULES: " + strings.ToUpper(rules.Name)
+}
+
+// difficultyScene chooses the difficulty of the AI before a one player match
+func (a *App) difficultyScene() Scene {
+	m := newMenu("DIFFICULTY")
+	for _, name := range aiProfileNames {
+		profile := aiProfiles[name]
+		if name == a.config.Difficulty.Name {
+			m.selected = len(m.items)
+		}
+		m.items = append(m.items, MenuItem{
+			label: strings.ToUpper(name),
+			action: func() {
+				a.config.Difficulty = profile
+				a.startMatch()
+			},
+		})
+	}
+	m.items = append(m.items, MenuItem{label: "BACK", action: func() { a.scenes.GoTo(a.modeScene()) }})
+	return a.menuScene(m, nil)
+}
+
+// settingsScene changes the settings, and goes back to the scene created by back
+func (a *App) settingsScene(back func() Scene) Scene {
+	var scene *MenuScene
+	m := newSettingsMenu(
+		func() AIProfile { return a.config.Difficulty },
+		func(profile AIProfile) {
+			a.config.Difficulty = profile
+			if a.match != nil {
+				a.match.game.setDifficulty(profile)
+			}
+		},
+		func(menu *Menu) { scene.menu = menu },
+		func() { a.scenes.GoTo(back()) },
+	)
+	scene = a.menuScene(m, nil)
+	return scene
+}
+
+// resultsScene shows the summary of the match that is over, on top of the final state of the match
+func (a *App) resultsScene() Scene {
+	g := a.match.game
+	m := newMenu(g.winner()+" WINS",
+		MenuItem{label: "REMATCH", action: func() {
+			g.restart()
+			a.scenes.GoTo(a.match)
+		}},
+		MenuItem{label: "CHANGE SETTINGS", action: func() { a.scenes.GoTo(a.settingsScene(a.resultsScene)) }},
+		MenuItem{label: "MAIN MENU", action: func() { a.scenes.GoTo(a.titleScene()) }},
+	)
+	m.info = []string{fmt.Sprintf("RULES  %s", g.rules)}
+	if g.rules.Sets > 1 {
+		m.info = append(m.info, fmt.Sprintf("SETS  %s", g.score.Sets()))
+	}
+	m.info = append(m.info,
+		fmt.Sprintf("FINAL SCORE  %s", g.score),
+		fmt.Sprintf("LONGEST VOLLEY  %d", g.longestVolley),
+		fmt.Sprintf("DURATION  %s", formatDuration(g.matchDuration())),
+	)
+	return a.menuScene(m, g.Draw)
+}
+
+// creditsScene shows who made the game, until a key is pressed
+func (a *App) creditsScene() Scene {
+	return &CreditsScene{app: a}
+}
+
+// newMatchGame creates the game of a new match with the current config
+func (a *App) newMatchGame(seed int64) *Game {
+	left, right := a.config.Left, a.config.Right
+	if left == "" {
+		left = "cpu"
+		if a.config.Mode == twoPlayers {
+			left = "ws"
+			if a.config.Player2 != "" {
+				left = a.config.Player2
+			}
+		}
+	}
+	if right == "" {
+		right = "keyboard"
+	}
+	enemy, err := newController(left, a.config.Difficulty)
+	if err != nil {
+		log.Fatal(err)
+	}
+	player, err := newController(right, a.config.Difficulty)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	g := newGame(seed, a.config.Mode)
+	g.debug = a.config.Debug
+	g.player.controller = player
+	g.enemy.controller = enemy
+	g.setDifficulty(a.config.Difficulty)
+	g.rules = a.config.Rules
+	return g
+}
+
+// startMatch starts a new match with the current config
+func (a *App) startMatch() {
+	seed := a.config.Seed
+	if a.match != nil {
+		// the first match uses the seed of the config, and every match picks the seed of the next one
+		seed = int64(newRand(a.match.game.seed).Uint64())
+	}
+	log.Printf("seed: %d", seed)
+	a.playMatch(a.newMatchGame(seed))
+}
+
+// continueMatch continues the saved match
+func (a *App) continueMatch(saved *SavedMatch) {
+	a.config.Mode = saved.Mode
+	a.config.Difficulty = saved.Difficulty
+	a.config.Rules = saved.Rules
+	g := a.newMatchGame(saved.Seed)
+	saved.apply(g)
+	a.playMatch(g)
+}
+
+// playMatch goes to the match played with the game g.
+// The saved match is removed, it is either continued by g or replaced by it.
+func (a *App) playMatch(g *Game) {
+	if a.config.SavePath != "" {
+		if err := os.Remove(a.config.SavePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("cannot remove the saved match: %v", err)
+		}
+	}
+
+	match := &MatchScene{app: a, game: g}
+	g.leave = func() {
+		match.left = true
+		a.scenes.GoTo(a.titleScene())
+	}
+	if a.config.RecordPath != "" {
+		match.recorder = newRecorder(g)
+	}
+	a.match = match
+	a.scenes.GoTo(match)
+}
+
+// MenuScene is a scene showing a menu, over a black screen or over something else
+type MenuScene struct {
+	menu *Menu
+	hud  *HUD
+
+	// Draws what is behind the menu (nil for a black screen)
+	background func(screen *ebiten.Image)
+}
+
+// menuScene creates a scene showing the menu m over the background (nil for a black screen)
+func (a *App) menuScene(m *Menu, background func(screen *ebiten.Image)) *MenuScene {
+	return &MenuScene{menu: m, hud: a.hud, background: background}
+}
+
+// Update moves through the menu
+func (s *MenuScene) Update(_ *SceneManager) error {
+	s.menu.Update()
+	return nil
+}
+
+// Draw draws the menu over its background
+func (s *MenuScene) Draw(screen *ebiten.Image) {
+	if s.background != nil {
+		s.background(screen)
+	}
+	s.menu.Draw(screen, s.hud)
+}
+
+// MatchScene is the scene where a match is played
+type MatchScene struct {
+	app *App
+
+	// The match
+	game *Game
+
+	// Records the replay of the match (nil if it is not recorded)
+	recorder *Recorder
+
+	// Whether the players quit the match from the pause menu (it is then not saved)
+	left bool
+}
+
+// Update plays the match, and shows the results when it is over
+func (s *MatchScene) Update(sm *SceneManager) error {
+	var err error
+	if s.recorder != nil {
+		err = s.recorder.Update()
+	} else {
+		err = s.game.Update()
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.game.state == gameOver {
+		sm.GoTo(s.app.resultsScene())
+	}
+	return nil
+}
+
+// Draw draws the match
+func (s *MatchScene) Draw(screen *ebiten.Image) {
+	s.game.Draw(screen)
+}
+
+// credits are the lines of the credits scene
+var credits = []string{
+	"PONG",
+	"",
+	"A REMAKE OF THE 1972 ARCADE GAME BY ATARI",
+	"WRITTEN IN GO WITH EBITENGINE",
+	"",
+	"PRESS ENTER",
+}
+
+// CreditsScene shows the credits, until serve or pause is pressed (or the screen is clicked or tapped)
+type CreditsScene struct {
+	app *App
+}
+
+// Update goes back to the title when a key is pressed
+func (s *CreditsScene) Update(sm *SceneManager) error {
+	_, _, tapped := pointerJustPressed()
+	if cfg.Controls.Serve.justPressed() || cfg.Controls.Pause.justPressed() || tapped {
+		sm.GoTo(s.app.titleScene())
+	}
+	return nil
+}
+
+// Draw draws the credits in the middle of the screen
+func (s *CreditsScene) Draw(screen *ebiten.Image) {
+	screen.Fill(color.Black)
+	lineHeight := 40
+	top := halfGameScreenHeight - lineHeight*len(credits)/2
+	for i, line := range credits {
+		face := s.app.hud.ResultDisplayFont
+		if i == 0 {
+			face = s.app.hud.MessageDisplayFont
+		}
+		s.app.hud.drawCentered(screen, line, face, top+lineHeight*i)
+	}
+}
+
+// quitScene ends the game, after fading out of the previous scene
+type quitScene struct{}
+
+// Update quits the game
+func (quitScene) Update(_ *SceneManager) error {
+	return ebiten.Termination
+}
+
+// Draw draws a black screen
+func (quitScene) Draw(screen *ebiten.Image) {
+	screen.Fill(color.Black)
+}
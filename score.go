@@ -1,6 +1,20 @@
 package main
 
-// Score stores the score of the player and the enemy
+import "fmt"
+
+// Score stores the score of the player and the enemy:
+// the points of the current set, and the number of sets won
 type Score struct {
-	player, enemy int
+	player, enemy         int
+	playerSets, enemySets int
+}
+
+// String returns the points as shown on the screen (enemy on the left, player on the right)
+func (s Score) String() string {
+	return fmt.Sprintf("%d - %d", s.enemy, s.player)
+}
+
+// Sets returns the sets won as shown on the screen (enemy on the left, player on the right)
+func (s Score) Sets() string {
+	return fmt.Sprintf("%d - %d", s.enemySets, s.playerSets)
 }
@@ -1,10 +1,12 @@
 package main
 
-// Game constants (global)
-const (
-	screenWidth          = 1280
-	screenHeight         = 720
+// Game settings (global), changed by the configuration at startup (see Config.apply)
+var (
+	screenWidth          = cfg.Screen.Width
+	screenHeight         = cfg.Screen.Height
 	halfGameScreenWidth  = screenWidth / 2
 	halfGameScreenHeight = screenHeight / 2
-	pointsToWin          = 10
 )
+
+// pointsToWin is the default of the points needed to win with the classic rules
+const pointsToWin = 10
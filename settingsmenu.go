@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// newSettingsMenu creates the menu to change the settings, during a match or from the title screen.
+// The difficulty is read with difficulty and changed with setDifficulty, show replaces the menu shown
+// by another one (e.g. the controls) and back leaves the menu.
+func newSettingsMenu(difficulty func() AIProfile, setDifficulty func(AIProfile), show func(*Menu), back func()) *Menu {
+	m := newMenu("SETTINGS")
+
+	m.items = append(m.items, MenuItem{
+		label: difficultyLabel(difficulty()),
+		action: func() {
+			// go to the next harder preset, and back to the easiest after the hardest
+			next := 0
+			for i, name := range aiProfileNames {
+				if name == difficulty().Name {
+					next = (i + 1) % len(aiProfileNames)
+				}
+			}
+			setDifficulty(aiProfiles[aiProfileNames[next]])
+			m.items[0].label = difficultyLabel(difficulty())
+		},
+	})
+
+	m.items = append(m.items, MenuItem{
+		label: onOff("FULLSCREEN", ebiten.IsFullscreen()),
+		action: func() {
+			on := !ebiten.IsFullscreen()
+			ebiten.SetFullscreen(on)
+			m.items[1].label = onOff("FULLSCREEN", on)
+		},
+	})
+
+	m.items = append(m.items, MenuItem{
+		label:  "CONTROLS",
+		action: func() { show(newControlsMenu(func() { show(m) })) },
+	})
+
+	m.items = append(m.items, MenuItem{label: "BACK", action: back})
+	return m
+}
+
+// difficultyLabel returns the label of the difficulty setting
+func difficultyLabel(profile AIProfile) string {
+	return "DIFFICULTY: " + strings.ToUpper(profile.Name)
+}
+
+// onOff returns the label of a setting that is on or off
+func onOff(name string, on bool) string {
+	if on {
+		return name + ": ON"
+	}
+	return name + ": OFF"
+}
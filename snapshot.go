@@ -0,0 +1,131 @@
+package main
+
+// Snapshot is a copy of the whole state of a game that affects the simulation.
+// Restoring it puts the game back exactly where it was, e.g. to roll back an online match
+// and simulate again with the input that arrived late.
+type Snapshot struct {
+	score         Score
+	state         GameState
+	turn          playerTurn
+	frame         int
+	volleyCount   int
+	longestVolley int
+	rng           Rand
+	ball          Ball
+	player        Paddle
+	enemy         Paddle
+
+	// The power-ups on the court, their effects and the extra balls
+	powerUps     []PowerUp
+	effects      []Effect
+	extraBalls   []Ball
+	powerUpClock int
+
+	// The state of the controllers (nil for a controller that has none)
+	playerController, enemyController any
+}
+
+// StatefulController is a controller that remembers things from one frame to the next.
+// Its state is saved and restored along with the game (see Snapshot).
+type StatefulController interface {
+	Controller
+
+	// saveState returns a copy of the state of the controller
+	saveState() any
+
+	// loadState puts the controller back in a state returned by saveState
+	loadState(state any)
+}
+
+// snapshot returns a copy of the state of the game
+func (g *Game) snapshot() Snapshot {
+	s := Snapshot{
+		score:            g.score,
+		state:            g.state,
+		turn:             g.turn,
+		frame:            g.frame,
+		volleyCount:      g.volleyCount,
+		longestVolley:    g.longestVolley,
+		rng:              *g.rng,
+		ball:             *g.ball,
+		player:           *g.player.paddle,
+		enemy:            *g.enemy.paddle,
+		playerController: saveControllerState(g.player.controller),
+		enemyController:  saveControllerState(g.enemy.controller),
+		effects:          append([]Effect(nil), g.effects...),
+		powerUpClock:     g.powerUpClock,
+	}
+	for _, p := range g.powerUps {
+		s.powerUps = append(s.powerUps, *p)
+	}
+	for _, b := range g.extraBalls {
+		s.extraBalls = append(s.extraBalls, *b)
+	}
+	return s
+}
+
+// restore puts the game back in the state of the snapshot s.
+// The controllers must be the same as when the snapshot was taken.
+func (g *Game) restore(s Snapshot) {
+	g.score = s.score
+	g.state = s.state
+	g.turn = s.turn
+	g.frame = s.frame
+	g.volleyCount = s.volleyCount
+	g.longestVolley = s.longestVolley
+	*g.rng = s.rng
+
+	// the sounds are not part of the state
+	sounds := g.ball.sounds
+	*g.ball = s.ball
+	g.ball.sounds = sounds
+
+	*g.player.paddle = s.player
+	*g.enemy.paddle = s.enemy
+
+	// the snapshot can be restored again, it keeps its own copies
+	g.powerUps, g.extraBalls = nil, nil
+	for _, p := range s.powerUps {
+		p := p
+		g.powerUps = append(g.powerUps, &p)
+	}
+	for _, b := range s.extraBalls {
+		b := b
+		b.sounds = sounds
+		g.extraBalls = append(g.extraBalls, &b)
+	}
+	g.effects = append([]Effect(nil), s.effects...)
+	g.powerUpClock = s.powerUpClock
+	g.updateObjects()
+
+	loadControllerState(g.player.controller, s.playerController)
+	loadControllerState(g.enemy.controller, s.enemyController)
+}
+
+func saveControllerState(c Controller) any {
+	if sc, ok := c.(StatefulController); ok {
+		return sc.saveState()
+	}
+	return nil
+}
+
+func loadControllerState(c Controller, state any) {
+	if sc, ok := c.(StatefulController); ok {
+		sc.loadState(state)
+	}
+}
+
+// saveState returns a copy of the AI, with its own copy of what it has seen
+func (ai *AIController) saveState() any {
+	saved := *ai
+	saved.seen = append([]ballState(nil), ai.seen...)
+	return saved
+}
+
+// loadState restores the AI from a copy returned by saveState
+func (ai *AIController) loadState(state any) {
+	saved := state.(AIController)
+	seen := append(ai.seen[:0], saved.seen...)
+	*ai = saved
+	ai.seen = seen
+}
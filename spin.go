@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// trailLength is the number of past positions of the ball drawn behind it while it spins
+const trailLength = 8
+
+// SpinConfig is how the ball spins, when the rules of the match allow it (see MatchRules.Spin).
+// A paddle moving when it hits the ball makes it spin, and a spinning ball curves: its direction turns
+// a little every frame, the way the paddle was moving. The spin fades away over time, and the walls change
+// the angle the ball bounces off at, then mirror its spin and take half of it.
+type SpinConfig struct {
+	// The spin given to the ball for every pixel per frame of the speed of the paddle, in degrees per frame
+	Transfer float64 `json:"transfer"`
+
+	// The maximum spin of the ball, in degrees per frame
+	Max float64 `json:"max"`
+
+	// The fraction of the spin that the ball keeps from one frame to the next
+	Decay float64 `json:"decay"`
+
+	// The number of frames of spin that a wall turns into a change of the bounce angle
+	WallGrip float64 `json:"wallGrip"`
+}
+
+// defaultSpinConfig returns the spin the game was designed with
+func defaultSpinConfig() SpinConfig {
+	return SpinConfig{Transfer: 0.04, Max: 0.6, Decay: 0.97, WallGrip: 20}
+}
+
+// validate returns an error if the spin makes no sense
+func (s SpinConfig) validate() error {
+	switch {
+	case s.Transfer < 0:
+		return fmt.Errorf("spin.transfer cannot be negative, got %v", s.Transfer)
+	case s.Max < 0 || s.Max >= 5:
+		return fmt.Errorf("spin.max must be between 0 and 5 degrees per frame, got %v", s.Max)
+	case s.Decay < 0 || s.Decay > 1:
+		return fmt.Errorf("spin.decay must be between 0 and 1, got %v", s.Decay)
+	case s.WallGrip < 0:
+		return fmt.Errorf("spin.wallGrip cannot be negative, got %v", s.WallGrip)
+	}
+	return nil
+}
+
+// spinOff gives the ball the spin of the paddle p, that it has just bounced off
+func (b *Ball) spinOff(p *Paddle) {
+	// the ball turns the way the paddle was moving: clockwise (on the screen) when it goes right
+	// and the paddle moves down, the other way round when it goes left
+	spin := cfg.Spin.Transfer * p.velocity.Y
+	if b.velocity.X < 0 {
+		spin = -spin
+	}
+	b.spin = math.Max(-cfg.Spin.Max, math.Min(cfg.Spin.Max, spin))
+}
+
+// curve turns the direction of the ball by its spin, and lets the spin fade away
+func (b *Ball) curve() {
+	if b.spin == 0 {
+		return
+	}
+	b.turn(b.spin)
+	b.spin *= cfg.Spin.Decay
+	if math.Abs(b.spin) < 0.001 {
+		b.spin = 0
+	}
+}
+
+// spinOffWall changes the angle the ball has just bounced off a wall at, by its spin,
+// unless that sends it back into the wall. The wall mirrors the spin, and takes half of it.
+func (b *Ball) spinOffWall() {
+	if b.spin == 0 {
+		return
+	}
+	velocity := b.velocity
+	b.turn(b.spin * cfg.Spin.WallGrip)
+	if (b.velocity.Y < 0) != (velocity.Y < 0) {
+		b.velocity = velocity
+	}
+	b.spin = -b.spin / 2
+}
+
+// turn rotates the velocity of the ball by the angle in degrees (clockwise on the screen), keeping its speed
+func (b *Ball) turn(angle float64) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	b.velocity = Vector2D{
+		X: b.velocity.X*cos - b.velocity.Y*sin,
+		Y: b.velocity.X*sin + b.velocity.Y*cos,
+	}
+}
+
+// updateTrail remembers the position of the ball while it spins, and forgets it little by little otherwise
+func (b *Ball) updateTrail() {
+	copy(b.trail[1:], b.trail[:trailLength-1])
+	b.trail[0] = b.pos
+	if b.trailSize < trailLength && b.spin != 0 {
+		b.trailSize++
+	} else if b.spin == 0 && b.trailSize > 0 {
+		b.trailSize--
+	}
+}
+
+// drawTrail draws the past positions of the ball behind it, fading out and the more opaque the more it spins
+func (b *Ball) drawTrail(screen *ebiten.Image) {
+	strength := 0.3
+	if cfg.Spin.Max > 0 {
+		strength += 0.7 * math.Min(1, math.Abs(b.spin)/cfg.Spin.Max)
+	}
+	size := float32(b.position.Width)
+	for i := b.trailSize - 1; i >= 0; i-- {
+		alpha := strength * float64(trailLength-i) / float64(trailLength+1)
+		c := color.RGBA{R: uint8(255 * alpha), G: uint8(255 * alpha), B: uint8(255 * alpha), A: uint8(255 * alpha)}
+		shrink := size * float32(i+1) / float32(2*trailLength)
+		p := b.trail[i]
+		vector.DrawFilledRect(screen, float32(p.X)+shrink/2, float32(p.Y)+shrink/2, size-shrink, size-shrink, c)
+	}
+}
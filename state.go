@@ -10,6 +10,23 @@ const (
 	firstService
 )
 
+// GameMode is who is playing the match
+type GameMode int
+
+const (
+	// onePlayer is a player (right paddle) versus the computer (left paddle)
+	onePlayer GameMode = iota
+
+	// twoPlayers is two players on the same keyboard, player 1 on the right and player 2 on the left
+	twoPlayers
+)
+
+// gameModeNames maps the name of each game mode (as used on the command line) to the mode
+var gameModeNames = map[string]GameMode{
+	"1p": onePlayer,
+	"2p": twoPlayers,
+}
+
 type playerTurn int
 
 const (
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math"
+
+	"github.com/drpaneas/rect"
+)
+
+// maxSweepHits limits how many times the ball can hit something during a single frame
+const maxSweepHits = 4
+
+// AABB is an axis-aligned box, with a position that does not have to be a whole number of pixels
+type AABB struct {
+	X, Y, Width, Height float64
+}
+
+// boxOf returns the box at the position pos (with sub-pixel precision) with the size of the rectangle r
+func boxOf(pos Vector2D, r rect.Rectangle) AABB {
+	return AABB{X: pos.X, Y: pos.Y, Width: float64(r.Width), Height: float64(r.Height)}
+}
+
+// sweep moves the box a by d, and returns when it starts to touch the box b during the move
+// (the time of impact, from 0 at the start to 1 at the end) and the normal of the face of b it touches.
+// The normal has both X and Y set when a hits a corner of b exactly.
+// hit is false if a does not touch b during the move, or if it already overlaps b at the start.
+func sweep(a AABB, d Vector2D, b AABB) (t float64, normal Vector2D, hit bool) {
+	// when a enters and leaves the slab of b on each axis
+	xEntry, xExit, ok := sweepAxis(a.X, a.Width, d.X, b.X, b.Width)
+	if !ok {
+		return 0, Vector2D{}, false
+	}
+	yEntry, yExit, ok := sweepAxis(a.Y, a.Height, d.Y, b.Y, b.Height)
+	if !ok {
+		return 0, Vector2D{}, false
+	}
+
+	// a touches b once it is within both slabs, until it leaves one of them
+	entry := math.Max(xEntry, yEntry)
+	exit := math.Min(xExit, yExit)
+	if entry >= exit || entry < 0 || entry > 1 {
+		return 0, Vector2D{}, false
+	}
+
+	if xEntry >= yEntry {
+		normal.X = -math.Copysign(1, d.X)
+	}
+	if yEntry >= xEntry {
+		normal.Y = -math.Copysign(1, d.Y)
+	}
+	return entry, normal, true
+}
+
+// sweepAxis returns when a segment (position, size) moving by d enters and leaves another segment on the same axis.
+// A segment that does not move is within the other one all the time, or never (ok is false).
+func sweepAxis(position, size, d, other, otherSize float64) (entry, exit float64, ok bool) {
+	switch {
+	case d > 0:
+		return (other - (position + size)) / d, (other + otherSize - position) / d, true
+	case d < 0:
+		return (other + otherSize - position) / d, (other - (position + size)) / d, true
+	case position < other+otherSize && other < position+size:
+		return math.Inf(-1), math.Inf(1), true
+	}
+	return 0, 0, false
+}
+
+// moveBall moves the ball by its velocity, and makes it bounce off what it hits on the way:
+// the front of a paddle (see handlePaddleCollision), the top or bottom of a paddle, and the walls.
+// The ball is swept from its position to where it goes, so it cannot go through a paddle however fast it is.
+// A ball held by a sticky paddle moves with the paddle instead.
+func (g *Game) moveBall(ball *Ball) error {
+	if ball.held > 0 {
+		return g.holdBall(ball)
+	}
+	if err := g.separate(ball); err != nil {
+		return err
+	}
+	box := boxOf(ball.pos, ball.position)
+
+	remaining := 1.0 // the fraction of the frame left to move
+	for i := 0; i < maxSweepHits && remaining > 0; i++ {
+		d := Vector2D{X: ball.velocity.X * remaining, Y: ball.velocity.Y * remaining}
+
+		// find what the ball hits first
+		first := 2.0
+		var normal Vector2D
+		var holder PaddleHolder
+		for _, h := range []PaddleHolder{g.player, g.enemy} {
+			p := h.GetPaddle()
+			if t, n, hit := sweep(box, d, boxOf(p.pos, p.position)); hit && t < first {
+				first, normal, holder = t, n, h
+			}
+		}
+		if t, hit := sweepWalls(box, d); hit && t < first {
+			first, normal, holder = t, Vector2D{Y: -math.Copysign(1, d.Y)}, nil
+		}
+		if first > 1 {
+			box.X += d.X
+			box.Y += d.Y
+			break
+		}
+
+		box.X += d.X * first
+		box.Y += d.Y * first
+		remaining *= 1 - first
+		ball.moveTo(box.X, box.Y)
+
+		switch {
+		case holder == nil:
+			box.Y = math.Max(0, math.Min(float64(screenHeight)-box.Height, box.Y))
+			ball.moveTo(box.X, box.Y)
+			ball.bounceOffWall()
+		case normal.X != 0 && (holder == g.player) == (normal.X < 0):
+			// the front of the paddle (or its corner) sends the ball back
+			if err := g.handlePaddleCollision(ball, holder); err != nil {
+				return err
+			}
+			box.X = ball.pos.X
+			if ball.held > 0 {
+				// caught by a sticky paddle, where it hit it
+				box.Y = ball.pos.Y
+				remaining = 0
+			}
+		default:
+			// the top, the bottom or the back of the paddle deflects the ball, which goes on towards the goal
+			if err := ball.playSound("paddle"); err != nil {
+				return err
+			}
+			if normal.X != 0 {
+				ball.velocity.X = math.Copysign(ball.velocity.X, normal.X)
+			}
+			if normal.Y != 0 {
+				ball.velocity.Y = math.Copysign(ball.velocity.Y, normal.Y)
+			}
+		}
+	}
+
+	ball.moveTo(box.X, box.Y)
+	return nil
+}
+
+// separate moves the ball out of a paddle that overlaps it before the ball moves, which happens when the paddle moves into it.
+// The paddle pushes the ball along: the ball goes out of its top or bottom, at least as fast as the paddle.
+// When the paddle did not move into it, or there is no room between the paddle and the wall, the ball goes out the shortest way.
+// Only the sweep can send the ball back, when it hits the front of a paddle.
+func (g *Game) separate(ball *Ball) error {
+	box := boxOf(ball.pos, ball.position)
+	for _, h := range []PaddleHolder{g.player, g.enemy} {
+		p := h.GetPaddle()
+		b := boxOf(p.pos, p.position)
+		if !overlaps(box, b) {
+			continue
+		}
+
+		above, below := b.Y-box.Height, b.Y+b.Height
+		fitsAbove, fitsBelow := above >= 0, below+box.Height <= float64(screenHeight)
+		var push float64 // the way the ball is pushed out vertically, 0 if it goes out of a side
+		switch {
+		case p.velocity.Y < 0 && fitsAbove:
+			push = -1
+		case p.velocity.Y > 0 && fitsBelow:
+			push = 1
+		default:
+			// the shortest way out
+			out := math.Min(box.X+box.Width-b.X, b.X+b.Width-box.X)
+			if up := box.Y + box.Height - b.Y; fitsAbove && up < out {
+				push, out = -1, up
+			}
+			if down := b.Y + b.Height - box.Y; fitsBelow && down < out {
+				push = 1
+			}
+		}
+
+		switch {
+		case push < 0:
+			box.Y = above
+		case push > 0:
+			box.Y = below
+		case box.X+box.Width/2 < b.X+b.Width/2:
+			box.X = b.X - box.Width
+		default:
+			box.X = b.X + b.Width
+		}
+		ball.moveTo(box.X, box.Y)
+		if push == 0 {
+			continue
+		}
+
+		if err := ball.playSound("paddle"); err != nil {
+			return err
+		}
+		ball.velocity.Y = math.Copysign(math.Max(math.Abs(ball.velocity.Y), math.Abs(p.velocity.Y)), push)
+	}
+	return nil
+}
+
+// overlaps returns true if the boxes a and b overlap (touching is not overlapping)
+func overlaps(a, b AABB) bool {
+	return a.X < b.X+b.Width && b.X < a.X+a.Width && a.Y < b.Y+b.Height && b.Y < a.Y+a.Height
+}
+
+// sweepWalls returns when the box moving by d hits the top or the bottom wall
+func sweepWalls(a AABB, d Vector2D) (t float64, hit bool) {
+	switch {
+	case d.Y < 0 && a.Y >= 0:
+		t = -a.Y / d.Y
+	case d.Y > 0 && a.Y+a.Height <= float64(screenHeight):
+		t = (float64(screenHeight) - a.Y - a.Height) / d.Y
+	default:
+		return 0, false
+	}
+	return t, t <= 1
+}